@@ -1,11 +1,12 @@
 package asset
 
 import (
-	"bufio"
 	"bytes"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"mime"
 	"net/http"
 	"os"
@@ -23,11 +24,81 @@ const (
 	JPEG                   = "image/jpeg"
 	PNG                    = "image/png"
 	WEBP                   = "image/webp"
+	AVIF                   = "image/avif"
+	GIF                    = "image/gif"
+	HEIC                   = "image/heic"
+	HEIF                   = "image/heif"
+	TIFF                   = "image/tiff"
+	BMP                    = "image/bmp"
+	JXL                    = "image/jxl"
+	SVG                    = "image/svg+xml"
+	PDF                    = "application/pdf"
 	DefaultImageType       = vips.ImageTypeJPEG
 	DefaultInterestingType = vips.InterestingNone
+	DefaultTiffCompression = vips.TiffCompressionLzw
+	DefaultFit             = FitFill
+	// AllFrames keeps every frame of animated images
+	AllFrames = -1
 )
 
-var imageTypes = []string{JPEG, PNG, WEBP}
+// DefaultBackground is white
+var DefaultBackground = vips.ColorRGBA{R: 255, G: 255, B: 255, A: 255}
+
+// JPEG XL is detected but isn't an image type as govips can't decode it yet
+var imageTypes = []string{JPEG, PNG, WEBP, AVIF, GIF, HEIC, HEIF, TIFF, BMP, SVG}
+
+// vectorTypes are rendered at the density needed for the requested size
+var vectorTypes = []string{SVG, PDF}
+
+// ErrFrameOutOfRange is returned when resizing a frame the image doesn't have
+var ErrFrameOutOfRange = errors.New("frame out of range")
+
+// ErrCropOutOfBounds is returned when the area to crop to is outside the image
+var ErrCropOutOfBounds = errors.New("crop out of bounds")
+
+// ftypBrands maps the brands of ISO base media files to their MIME type
+var ftypBrands = map[string]string{
+	"avif": AVIF,
+	"avis": AVIF,
+	"heic": HEIC,
+	"heix": HEIC,
+	"heim": HEIC,
+	"heis": HEIC,
+	"hevc": HEIC,
+	"hevx": HEIC,
+	"hevm": HEIC,
+	"hevs": HEIC,
+	// generic HEIF brands, used when no more specific brand is found
+	"mif1": HEIF,
+	"msf1": HEIF,
+}
+
+// signatures are the magic numbers of formats http.DetectContentType doesn't know
+var signatures = []struct {
+	magic       string
+	contentType string
+}{
+	{"II*\x00", TIFF},
+	{"MM\x00*", TIFF},
+	{"\xff\x0a", JXL},
+	{"\x00\x00\x00\x0cJXL \x0d\x0a\x87\x0a", JXL},
+}
+
+func init() {
+	// older systems don't know about these
+	_ = mime.AddExtensionType(".avif", AVIF)
+	_ = mime.AddExtensionType(".heic", HEIC)
+	_ = mime.AddExtensionType(".heif", HEIF)
+	_ = mime.AddExtensionType(".tiff", TIFF)
+	_ = mime.AddExtensionType(".bmp", BMP)
+	_ = mime.AddExtensionType(".jxl", JXL)
+}
+
+// defaultDensity is the DPI vector images are rendered at by default
+const defaultDensity = 72
+
+// SniffLen is the amount of bytes used to detect the content type
+const SniffLen = 512
 
 type Asset struct {
 	File        *os.File
@@ -39,72 +110,259 @@ type Asset struct {
 	PathPrefix string
 	Path       string
 	Sha256     string
+	Size       int64
 	Type       string
-
-	buf bytes.Buffer
+	// Width and Height are the dimensions of the last resized image
+	Width  int
+	Height int
 }
 
+// New streams r to a temporary file, hashing and sniffing its content type along
+// the way. Images aren't decoded until they're resized.
 func New(r io.Reader) (*Asset, error) {
 	f, err := util.CreateTempFile()
 	if err != nil {
 		return nil, err
 	}
 
-	var buf bytes.Buffer
-	if _, err := io.Copy(&buf, r); err != nil {
-		log.Error().Msgf("Failed to copy buffer: %v", err)
-		return nil, err
-	}
-
-	_, err = f.Write(buf.Bytes())
-	if err != nil {
-		log.Error().Msgf("Failed to write buffer to temp file: %v", err)
-		return nil, err
-	}
-
-	// rewind file
-	f.Seek(0, 0)
-
 	a := &Asset{
 		File: f,
 	}
 
-	err = a.load()
+	err = a.load(r)
 	if err != nil {
+		util.CleanupTempFile(f)
 		return nil, err
 	}
 
 	return a, nil
 }
 
+// Fit is how images are resized when both a width and a height are given
+type Fit int
+
+const (
+	// FitFill stretches images to the exact size
+	FitFill Fit = iota
+	// FitCover crops images to the exact size
+	FitCover
+	// FitContain pads images to the exact size with the background color
+	FitContain
+	// FitInside makes images as large as possible within the size
+	FitInside
+	// FitOutside makes images as small as possible while covering the size
+	FitOutside
+)
+
+// Flip is how images are mirrored
+type Flip int
+
+const (
+	// FlipNone keeps images as they are
+	FlipNone Flip = iota
+	// FlipHorizontal mirrors images left to right
+	FlipHorizontal
+	// FlipVertical mirrors images top to bottom
+	FlipVertical
+)
+
+// Length is a distance in pixels or in percent of an image's width or height
+type Length struct {
+	Value   float64
+	Percent bool
+}
+
+// Region is an area of an image
+type Region struct {
+	Left   Length
+	Top    Length
+	Width  Length
+	Height Length
+}
+
+// FocalPoint is where the subject of an image is, as fractions of its width and height
+type FocalPoint struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// Metadata describes the image an asset renders to
+type Metadata struct {
+	Width  int
+	Height int
+	// Pages is the number of frames or pages
+	Pages int
+}
+
 type ResizeParams struct {
 	Width       int
 	Height      int
 	Quality     int
 	ImageType   vips.ImageType
 	Interesting vips.Interesting
+	// Effort is the CPU effort spent compressing AVIF between 1 and 9, 0 means the default
+	Effort   int
+	Lossless bool
+	// Frame is the frame of animated images to keep, AllFrames keeps them all when
+	// the output format supports animation
+	Frame int
+	// Compression is the compression used by TIFF
+	Compression vips.TiffCompression
+	// BitDepth is the bits per sample of PNG and TIFF, 8 or 16, 0 keeps the source's
+	BitDepth int
+	Fit      Fit
+	// Rotate is the angle in degrees images are rotated clockwise by, between 0
+	// and 360. The corners uncovered by angles that aren't a multiple of 90 are
+	// filled with Background.
+	Rotate float64
+	Flip   Flip
+	// Crop is the area images are cropped to before resizing, the whole image when
+	// its width is 0
+	Crop Region
+	// FocalPoint is kept in the middle of images cropped by FitCover when
+	// UseFocalPoint is set, instead of using Interesting
+	FocalPoint    FocalPoint
+	UseFocalPoint bool
+	// DPRQuality is the JPEG and WebP quality used for high density displays
+	// when no Quality is set, 0 means the default
+	DPRQuality int
+	// WithoutEnlargement keeps images from being made larger than their source
+	WithoutEnlargement bool
+	// Background fills the padding added by FitContain, transparent when its alpha is 0
+	Background vips.ColorRGBA
+	// Grayscale removes the colors of images
+	Grayscale bool
+	// Brightness, Contrast and Saturation change images by -100 to 100 percent
+	Brightness int
+	Contrast   int
+	Saturation int
+	// Gamma brightens images above 1 and darkens them below, 0 means unchanged
+	Gamma float64
+	// Tint multiplies the colors of images by its own when its alpha isn't 0
+	Tint vips.ColorRGBA
+	// Blur and Sharpen are the sigma of the gaussian used, 0 means none
+	Blur    float64
+	Sharpen float64
+	Overlay Overlay
+	// OverlayImage holds the image of Overlay when its Id is set, it isn't part of Hash
+	OverlayImage []byte
+	// Text is drawn over the overlay when its Text isn't empty
+	Text Text
+	// AcceptAVIF and AcceptWebP are the formats the client accepts, used by the
+	// handlers when no ImageType is requested
+	AcceptAVIF bool
+	AcceptWebP bool
 }
 
 func NewResizeParams() *ResizeParams {
 	return &ResizeParams{
 		ImageType:   DefaultImageType,
 		Interesting: DefaultInterestingType,
+		Frame:       AllFrames,
+		Compression: DefaultTiffCompression,
+		Fit:         DefaultFit,
+		Background:  DefaultBackground,
+		Overlay:     Overlay{Opacity: 1},
+	}
+}
+
+// Hash returns a canonical hash of the params suitable for cache keys
+// and entity tags. Fields must remain values, not pointers or slices, for it to be stable.
+func (rp *ResizeParams) Hash() string {
+	p := *rp
+	// the overlay image is identified by Overlay.Id
+	p.OverlayImage = nil
+	h := sha256.Sum256([]byte(fmt.Sprintf("%+v", p)))
+	return fmt.Sprintf("%x", h[:8])
+}
+
+// Sanitize strips scripts, event handlers and external references from SVG
+// assets. The asset is hashed again as its content changes.
+func (a *Asset) Sanitize() error {
+	if a.ContentType != SVG {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	err := sanitizeSVG(&buf, a.File)
+	if err != nil {
+		a.rewind()
+		return err
+	}
+
+	err = a.File.Truncate(0)
+	if err != nil {
+		log.Error().Msgf("Failed to truncate file %s: %v", a.File.Name(), err)
+		return err
+	}
+	a.rewind()
+
+	return a.load(&buf)
+}
+
+// Metadata returns the dimensions of the first page of an image or document
+// and how many pages it has
+func (a *Asset) Metadata() (*Metadata, error) {
+	defer a.rewind()
+
+	if a.Type != IMAGE && !IsVector(a.ContentType) {
+		return nil, errors.New("file type doesn't have image metadata")
+	}
+
+	image, err := vips.LoadImageFromFile(a.File.Name(), vips.NewImportParams())
+	if err != nil {
+		log.Error().Msgf("Failed to load image: %v", err)
+		return nil, err
+	}
+	defer image.Close()
+
+	m := &Metadata{
+		Width:  image.Width(),
+		Height: image.PageHeight(),
+		Pages:  image.Pages(),
+	}
+	// images are rendered upright so report the size they're displayed at
+	if image.Orientation() >= 5 {
+		m.Width, m.Height = m.Height, m.Width
 	}
+	return m, nil
 }
 
 func (a *Asset) Resize(rp *ResizeParams) ([]byte, error) {
 	defer a.rewind()
 
-	if a.Type != IMAGE {
+	if a.Type != IMAGE && !IsVector(a.ContentType) {
 		return nil, errors.New("file type doesn't support resizing")
 	}
 
-	image, err := vips.LoadImageFromBuffer(a.buf.Bytes(), vips.NewImportParams())
+	image, err := a.loadImage(rp)
 	if err != nil {
-		log.Error().Msgf("Failed to load image: %v", err)
+		if err != ErrFrameOutOfRange {
+			log.Error().Msgf("Failed to load image: %v", err)
+		}
+		return nil, err
+	}
+	defer image.Close()
+
+	err = orient(image, rp)
+	if err != nil {
+		log.Error().Msgf("Failed to rotate image: %v", err)
 		return nil, err
 	}
 
+	if rp.Crop.Width.Value > 0 {
+		err = extractArea(image, rp.Crop)
+		if err != nil {
+			if err != ErrCropOutOfBounds {
+				log.Error().Msgf("Failed to crop image: %v", err)
+			}
+			return nil, err
+		}
+	}
+
+	// frames of animated images are stacked vertically, each one page high
+	animated := image.PageHeight() < image.Height()
+
 	var force bool
 	if rp.Width > 0 && rp.Height > 0 {
 		force = true
@@ -114,34 +372,68 @@ func (a *Asset) Resize(rp *ResizeParams) ([]byte, error) {
 		rp.Width = image.Width()
 	}
 	if rp.Height == 0 {
-		rp.Height = image.Height()
+		rp.Height = image.PageHeight()
 	}
 
 	log.Debug().Msgf(
-		"Resize called with: width: %d height: %d quality: %d imageType: %s interesting: %s",
+		"Resize called with: width: %d height: %d quality: %d imageType: %s interesting: %s fit: %s",
 		rp.Width,
 		rp.Height,
 		rp.Quality,
 		strings.Split(rp.ImageType.FileExt(), ".")[1],
 		InterestingTypesToString[rp.Interesting],
+		FitTypesToString[rp.Fit],
 	)
 
-	if !force {
-		err = image.Thumbnail(rp.Width, rp.Height, rp.Interesting)
+	if animated {
+		err = resizeFrames(image, rp, force)
 	} else {
-		err = image.ThumbnailWithSize(rp.Width, rp.Height, rp.Interesting, vips.SizeForce)
+		err = resize(image, rp, force)
 	}
 	if err != nil {
 		log.Error().Msgf("Failed to create thumbnail: %v", err)
 		return nil, err
 	}
 
-	err = image.RemoveMetadata()
+	err = applyFilters(image, rp)
 	if err != nil {
-		log.Error().Msgf("Failed to remove metadata: %v", err)
+		log.Error().Msgf("Failed to apply filters: %v", err)
 		return nil, err
 	}
 
+	if rp.Overlay.Id != "" {
+		err = composite(image, rp)
+		if err != nil {
+			log.Error().Msgf("Failed to composite overlay: %v", err)
+			return nil, err
+		}
+	}
+
+	if rp.Text.Text != "" {
+		err = drawText(image, rp)
+		if err != nil {
+			log.Error().Msgf("Failed to draw text: %v", err)
+			return nil, err
+		}
+	}
+
+	// frame delays and the loop count are kept in the metadata of animated images
+	if !animated {
+		err = image.RemoveMetadata()
+		if err != nil {
+			log.Error().Msgf("Failed to remove metadata: %v", err)
+			return nil, err
+		}
+	}
+
+	if rp.BitDepth > 0 && (rp.ImageType == vips.ImageTypePNG || rp.ImageType == vips.ImageTypeTIFF) {
+		err = setBitDepth(image, rp.BitDepth)
+		if err != nil {
+			log.Error().Msgf("Failed to set bit depth: %v", err)
+			return nil, err
+		}
+	}
+
 	var b []byte
 	var exportErr error
 	switch rp.ImageType {
@@ -149,6 +441,8 @@ func (a *Asset) Resize(rp *ResizeParams) ([]byte, error) {
 		p := vips.NewJpegExportParams()
 		if rp.Quality > 0 {
 			p.Quality = rp.Quality
+		} else if rp.DPRQuality > 0 {
+			p.Quality = rp.DPRQuality
 		}
 		b, _, exportErr = image.ExportJpeg(p)
 	case vips.ImageTypePNG:
@@ -157,8 +451,34 @@ func (a *Asset) Resize(rp *ResizeParams) ([]byte, error) {
 		p := vips.NewWebpExportParams()
 		if rp.Quality > 0 {
 			p.Quality = rp.Quality
+		} else if rp.DPRQuality > 0 {
+			p.Quality = rp.DPRQuality
 		}
+		p.Lossless = rp.Lossless
 		b, _, exportErr = image.ExportWebp(p)
+	case vips.ImageTypeAVIF:
+		p := vips.NewAvifExportParams()
+		if rp.Quality > 0 {
+			p.Quality = rp.Quality
+		}
+		if rp.Effort > 0 {
+			p.Speed = 9 - rp.Effort
+		}
+		p.Lossless = rp.Lossless
+		b, _, exportErr = image.ExportAvif(p)
+	case vips.ImageTypeGIF:
+		p := vips.NewGifExportParams()
+		if rp.Quality > 0 {
+			p.Quality = rp.Quality
+		}
+		b, _, exportErr = image.ExportGIF(p)
+	case vips.ImageTypeTIFF:
+		p := vips.NewTiffExportParams()
+		if rp.Quality > 0 {
+			p.Quality = rp.Quality
+		}
+		p.Compression = rp.Compression
+		b, _, exportErr = image.ExportTiff(p)
 	default:
 		return nil, errors.New("unknown image type")
 	}
@@ -170,42 +490,473 @@ func (a *Asset) Resize(rp *ResizeParams) ([]byte, error) {
 
 	a.setMimeTypeFromExt(rp.ImageType.FileExt())
 	a.setExtensionsFromMimeType(a.ContentType)
+	a.Width = image.Width()
+	a.Height = image.PageHeight()
 
 	return b, nil
 }
 
-func (a *Asset) load() error {
-	defer a.rewind()
+// resize resizes image to the size in rp according to its fit
+func resize(image *vips.ImageRef, rp *ResizeParams, force bool) error {
+	size := vips.SizeBoth
+	if rp.WithoutEnlargement {
+		size = vips.SizeDown
+	}
 
-	r := bufio.NewReader(a.File)
-	var buf bytes.Buffer
-	tee := io.TeeReader(r, &buf)
+	if !force {
+		return image.ThumbnailWithSize(rp.Width, rp.Height, rp.Interesting, size)
+	}
+
+	switch rp.Fit {
+	case FitCover:
+		if rp.UseFocalPoint {
+			return coverFocalPoint(image, rp)
+		}
+		interesting := rp.Interesting
+		if interesting == vips.InterestingNone {
+			interesting = vips.InterestingCentre
+		}
+		return image.ThumbnailWithSize(rp.Width, rp.Height, interesting, size)
+	case FitContain:
+		err := image.ThumbnailWithSize(rp.Width, rp.Height, vips.InterestingNone, size)
+		if err != nil {
+			return err
+		}
+		return embed(image, rp.Width, rp.Height, rp.Background)
+	case FitInside:
+		return image.ThumbnailWithSize(rp.Width, rp.Height, vips.InterestingNone, size)
+	case FitOutside:
+		scale := math.Max(
+			float64(rp.Width)/float64(image.Width()),
+			float64(rp.Height)/float64(image.Height()),
+		)
+		if rp.WithoutEnlargement {
+			scale = math.Min(scale, 1)
+		}
+		width := int(math.Round(float64(image.Width()) * scale))
+		height := int(math.Round(float64(image.Height()) * scale))
+		return image.ThumbnailWithSize(width, height, vips.InterestingNone, vips.SizeForce)
+	default:
+		width, height := rp.Width, rp.Height
+		if rp.WithoutEnlargement {
+			width = min(width, image.Width())
+			height = min(height, image.Height())
+		}
+		return image.ThumbnailWithSize(width, height, rp.Interesting, vips.SizeForce)
+	}
+}
+
+// orient rotates image upright according to its EXIF orientation, then rotates
+// and flips it as rp asks
+func orient(image *vips.ImageRef, rp *ResizeParams) error {
+	err := image.AutoRotate()
+	if err != nil {
+		return err
+	}
+
+	err = rotate(image, rp.Rotate, rp.Background)
+	if err != nil {
+		return err
+	}
+
+	switch rp.Flip {
+	case FlipHorizontal:
+		return image.Flip(vips.DirectionHorizontal)
+	case FlipVertical:
+		// flipping the frames of animated images together would reverse their order
+		if image.PageHeight() < image.Height() {
+			err = rotate(image, 180, rp.Background)
+			if err != nil {
+				return err
+			}
+			return image.Flip(vips.DirectionHorizontal)
+		}
+		return image.Flip(vips.DirectionVertical)
+	default:
+		return nil
+	}
+}
+
+// rotate rotates image clockwise by angle degrees, the corners uncovered by
+// angles that aren't a multiple of 90 are filled with background
+func rotate(image *vips.ImageRef, angle float64, background vips.ColorRGBA) error {
+	switch angle {
+	case 0:
+		return nil
+	case 90:
+		return image.Rotate(vips.Angle90)
+	case 180:
+		// rotating the frames of animated images together would reverse their order
+		if image.PageHeight() < image.Height() {
+			err := image.Rotate(vips.Angle90)
+			if err != nil {
+				return err
+			}
+			return image.Rotate(vips.Angle90)
+		}
+		return image.Rotate(vips.Angle180)
+	case 270:
+		return image.Rotate(vips.Angle270)
+	}
+
+	// the background has a value for each of the red, green and blue bands
+	if image.Bands() < 3 {
+		err := image.ToColorSpace(vips.InterpretationSRGB)
+		if err != nil {
+			return err
+		}
+	}
+	if background.A == 0 && !image.HasAlpha() {
+		err := image.AddAlpha()
+		if err != nil {
+			return err
+		}
+	}
+	return image.Similarity(1, angle, &background, 0, 0, 0, 0)
+}
+
+// coverFocalPoint scales image to cover the size in rp and crops it around the focal point
+func coverFocalPoint(image *vips.ImageRef, rp *ResizeParams) error {
+	scale := math.Max(
+		float64(rp.Width)/float64(image.Width()),
+		float64(rp.Height)/float64(image.Height()),
+	)
+	if rp.WithoutEnlargement {
+		scale = math.Min(scale, 1)
+	}
+	width := int(math.Max(math.Round(float64(image.Width())*scale), 1))
+	height := int(math.Max(math.Round(float64(image.Height())*scale), 1))
+	err := image.ThumbnailWithSize(width, height, vips.InterestingNone, vips.SizeForce)
+	if err != nil {
+		return err
+	}
+
+	width = min(rp.Width, image.Width())
+	height = min(rp.Height, image.Height())
+	left := focus(rp.FocalPoint.X, image.Width(), width)
+	top := focus(rp.FocalPoint.Y, image.Height(), height)
+	return image.ExtractArea(left, top, width, height)
+}
+
+// focus returns where a span of length centered on the fraction f of size
+// starts, moved so it stays within size
+func focus(f float64, size int, length int) int {
+	start := int(math.Round(f*float64(size) - float64(length)/2))
+	if start < 0 {
+		return 0
+	}
+	return min(start, size-length)
+}
+
+// extractArea crops image to r, the parts of r outside of the image are ignored
+func extractArea(image *vips.ImageRef, r Region) error {
+	width, height := image.Width(), image.PageHeight()
+	left := r.Left.pixels(width)
+	top := r.Top.pixels(height)
+	if left >= width || top >= height {
+		return ErrCropOutOfBounds
+	}
+
+	w := min(r.Width.pixels(width), width-left)
+	h := min(r.Height.pixels(height), height-top)
+	if w < 1 || h < 1 {
+		return ErrCropOutOfBounds
+	}
+	return image.ExtractArea(left, top, w, h)
+}
+
+// pixels returns the length in pixels of l for an image size pixels long
+func (l Length) pixels(size int) int {
+	if l.Percent {
+		return int(math.Round(l.Value * float64(size) / 100))
+	}
+	return int(l.Value)
+}
+
+// embed centers image in a width by height canvas filled with background
+func embed(image *vips.ImageRef, width int, height int, background vips.ColorRGBA) error {
+	left := (width - image.Width()) / 2
+	top := (height - image.Height()) / 2
+
+	if background.A == 0 {
+		err := image.AddAlpha()
+		if err != nil {
+			return err
+		}
+		// black is all zeros, transparent once there's an alpha channel
+		return image.Embed(left, top, width, height, vips.ExtendBlack)
+	}
+
+	// the background has a value for each of the red, green and blue bands
+	if image.Bands() < 3 {
+		err := image.ToColorSpace(vips.InterpretationSRGB)
+		if err != nil {
+			return err
+		}
+	}
+	return image.EmbedBackground(left, top, width, height, &vips.Color{
+		R: background.R,
+		G: background.G,
+		B: background.B,
+	})
+}
+
+// loadImage decodes the frames of the asset needed for rp. Every frame is only
+// loaded when the output can be animated, otherwise a single one is.
+func (a *Asset) loadImage(rp *ResizeParams) (*vips.ImageRef, error) {
+	p := vips.NewImportParams()
+	allFrames := false
+
+	switch {
+	case rp.Frame == AllFrames:
+		if canAnimate(rp.ImageType) && (a.ContentType == GIF || a.ContentType == WEBP) && !singleFrame(rp) {
+			p.NumPages.Set(-1)
+			allFrames = true
+		}
+	case rp.Frame > 0:
+		// images report how many frames they have whichever one is loaded
+		image, err := vips.LoadImageFromFile(a.File.Name(), p)
+		if err != nil {
+			return nil, err
+		}
+		pages := image.Pages()
+		image.Close()
+		if rp.Frame >= pages {
+			return nil, ErrFrameOutOfRange
+		}
+		p.Page.Set(rp.Frame)
+	}
+
+	if IsVector(a.ContentType) {
+		density, err := a.density(rp, p)
+		if err != nil {
+			return nil, err
+		}
+		p.Density.Set(density)
+	}
+
+	image, err := vips.LoadImageFromFile(a.File.Name(), p)
+	if err != nil {
+		return nil, err
+	}
+	if !allFrames && image.Pages() > 1 {
+		err = singlePage(image)
+		if err != nil {
+			image.Close()
+			return nil, err
+		}
+	}
+	return image, nil
+}
+
+// singlePage makes a single loaded page of image report it's the only one.
+// Loaders report how many pages the file has whichever are loaded, and govips
+// refuses to crop or rotate multi-page images or treats them as a grid of pages.
+// SetPages sets the count on the image it replaces in this version of govips, so
+// the page count is removed along with the rest of the metadata, which single
+// pages are exported without anyway. The orientation and ICC profile are kept.
+func singlePage(image *vips.ImageRef) error {
+	return image.RemoveMetadata()
+}
+
+// density returns the DPI a vector image should be loaded at with p so it's
+// never enlarged as a raster
+func (a *Asset) density(rp *ResizeParams, p *vips.ImportParams) (int, error) {
+	image, err := vips.LoadImageFromFile(a.File.Name(), p)
+	if err != nil {
+		return 0, err
+	}
+	defer image.Close()
+
+	scale := math.Max(
+		float64(rp.Width)/float64(image.Width()),
+		float64(rp.Height)/float64(image.Height()),
+	)
+	if scale < 1 || rp.WithoutEnlargement {
+		scale = 1
+	}
+	return int(math.Ceil(defaultDensity * scale)), nil
+}
+
+// singleFrame reports whether rp changes images in ways that only apply to a
+// single frame, such as cropping, so animated images keep their first one
+func singleFrame(rp *ResizeParams) bool {
+	return rp.Crop.Width.Value > 0 || math.Mod(rp.Rotate, 90) != 0
+}
+
+// setBitDepth converts image to 8 or 16 bits per sample
+func setBitDepth(image *vips.ImageRef, depth int) error {
+	grey := image.Bands() < 3
+	interpretation := vips.InterpretationSRGB
+	switch {
+	case depth == 16 && grey:
+		interpretation = vips.InterpretationGrey16
+	case depth == 16:
+		interpretation = vips.InterpretationRGB16
+	case grey:
+		interpretation = vips.InterpretationBW
+	}
+	return image.ToColorSpace(interpretation)
+}
+
+// canAnimate reports whether images of type t can hold more than one frame
+func canAnimate(t vips.ImageType) bool {
+	return t == vips.ImageTypeGIF || t == vips.ImageTypeWEBP
+}
+
+func min(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// resizeFrames resizes every frame of an animated image. Frames can't be cropped
+// or padded so FitCover and FitContain fit them inside the size instead.
+// Thumbnail would treat the frames as a single tall image.
+func resizeFrames(image *vips.ImageRef, rp *ResizeParams, force bool) error {
+	frames := image.Height() / image.PageHeight()
+	hscale := float64(rp.Width) / float64(image.Width())
+	vscale := float64(rp.Height) / float64(image.PageHeight())
+	switch {
+	case force && rp.Fit == FitFill:
+	case force && rp.Fit == FitOutside:
+		hscale = math.Max(hscale, vscale)
+		vscale = hscale
+	default:
+		hscale = math.Min(hscale, vscale)
+		vscale = hscale
+	}
+	if rp.WithoutEnlargement {
+		hscale = math.Min(hscale, 1)
+		vscale = math.Min(vscale, 1)
+	}
+
+	pageHeight := int(math.Round(float64(image.PageHeight()) * vscale))
+	if pageHeight < 1 {
+		pageHeight = 1
+	}
+
+	// scale the whole strip so it still splits evenly into frames
+	vscale = float64(pageHeight*frames) / float64(image.Height())
+	err := image.ResizeWithVScale(hscale, vscale, vips.KernelAuto)
+	if err != nil {
+		return err
+	}
+
+	return image.SetPageHeight(pageHeight)
+}
+
+func (a *Asset) load(r io.Reader) error {
+	defer a.rewind()
 
 	h := sha256.New()
-	if _, err := io.Copy(h, tee); err != nil {
-		log.Error().Msgf("Failed to hash file")
+	head := &headWriter{n: SniffLen}
+	n, err := io.Copy(io.MultiWriter(a.File, h, head), r)
+	if err != nil {
+		log.Error().Msgf("Failed to write temp file: %v", err)
 		return err
 	}
 
-	a.detectContentType(buf.Bytes())
+	a.detectContentType(head.buf)
+	// the root element of an SVG can be further in than the sniffed bytes
+	if strings.HasPrefix(a.ContentType, "text/") {
+		_, err = a.File.Seek(0, io.SeekStart)
+		if err != nil {
+			return err
+		}
+		if isSVG(a.File) {
+			a.setContentType(SVG)
+		}
+	}
+	a.Size = n
 	a.Sha256 = fmt.Sprintf("%x", h.Sum(nil))
 	a.Name = a.Sha256
 	a.Path, _ = util.GetFullPathFromSha256(a.Sha256)
 	prefix := strings.Split(a.Path, "/")[0:3]
 	a.PathPrefix = strings.Join(prefix, "/")
-	a.buf = buf
 
 	return nil
 }
 
-func (a *Asset) detectContentType(buf []byte) {
-	a.ContentType = http.DetectContentType(buf)
-	a.setExtensionsFromMimeType(a.ContentType)
+// DetectContentType returns the MIME type of the data in buf. It recognizes
+// ISO base media image formats on top of what http.DetectContentType does.
+func DetectContentType(buf []byte) string {
+	if ct := detectFtyp(buf); ct != "" {
+		return ct
+	}
+	for _, sig := range signatures {
+		if strings.HasPrefix(string(buf), sig.magic) {
+			return sig.contentType
+		}
+	}
+	if isSVG(bytes.NewReader(buf)) {
+		return SVG
+	}
+	return http.DetectContentType(buf)
+}
+
+// detectFtyp returns the MIME type of ISO base media files from the major and
+// compatible brands of their ftyp box, or an empty string.
+func detectFtyp(buf []byte) string {
+	if len(buf) < 12 || string(buf[4:8]) != "ftyp" {
+		return ""
+	}
+
+	// brands are the major brand, a version, then the compatible brands
+	end := int(binary.BigEndian.Uint32(buf[0:4]))
+	if end > len(buf) {
+		end = len(buf)
+	}
+	brands := []string{string(buf[8:12])}
+	for i := 16; i+4 <= end; i += 4 {
+		brands = append(brands, string(buf[i:i+4]))
+	}
+
+	var generic string
+	for _, b := range brands {
+		ct, ok := ftypBrands[b]
+		if !ok {
+			continue
+		}
+		if ct != HEIF {
+			return ct
+		}
+		generic = ct
+	}
+	return generic
+}
+
+// IsVector reports whether contentType is a vector image or document that can be rasterized
+func IsVector(contentType string) bool {
+	for _, t := range vectorTypes {
+		if t == contentType {
+			return true
+		}
+	}
+	return false
+}
+
+// IsImage reports whether contentType is an image type that can be resized
+func IsImage(contentType string) bool {
 	for _, t := range imageTypes {
-		if t == a.ContentType {
-			a.Type = strings.Split(a.ContentType, "/")[0]
+		if t == contentType {
+			return true
 		}
 	}
+	return false
+}
+
+func (a *Asset) detectContentType(buf []byte) {
+	a.setContentType(DetectContentType(buf))
+}
+
+func (a *Asset) setContentType(contentType string) {
+	a.ContentType = contentType
+	a.setExtensionsFromMimeType(a.ContentType)
+	if IsImage(a.ContentType) {
+		a.Type = strings.Split(a.ContentType, "/")[0]
+	}
 }
 
 func (a *Asset) setExtensionsFromMimeType(typ string) {
@@ -219,6 +970,8 @@ func (a *Asset) setExtensionsFromMimeType(typ string) {
 		switch typ {
 		case JPEG:
 			a.Ext = ".jpeg"
+		case TIFF:
+			a.Ext = ".tiff"
 		default:
 			a.Ext = exts[0]
 		}
@@ -235,3 +988,19 @@ func (a *Asset) rewind() {
 		log.Error().Msgf("Failed to seek file %s: %v", a.File.Name(), err)
 	}
 }
+
+// headWriter keeps the first n bytes written to it
+type headWriter struct {
+	buf []byte
+	n   int
+}
+
+func (w *headWriter) Write(p []byte) (int, error) {
+	if rem := w.n - len(w.buf); rem > 0 {
+		if len(p) < rem {
+			rem = len(p)
+		}
+		w.buf = append(w.buf, p[:rem]...)
+	}
+	return len(p), nil
+}
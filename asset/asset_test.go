@@ -8,6 +8,8 @@ import (
 
 	"github.com/davidbyttow/govips/v2/vips"
 	"github.com/stretchr/testify/assert"
+
+	"github.com/alexferl/air/util"
 )
 
 func TestAsset(t *testing.T) {
@@ -40,6 +42,9 @@ func TestAsset(t *testing.T) {
 		a, err := New(f)
 		assert.NoError(t, err)
 
+		fi, err := f.Stat()
+		assert.NoError(t, err)
+		assert.Equal(t, fi.Size(), a.Size)
 		assert.Equal(t, img.origExt, a.Ext)
 		assert.Equal(t, img.origType, a.ContentType)
 		assert.Equal(t, "image", a.Type)
@@ -59,3 +64,772 @@ func TestAsset(t *testing.T) {
 		os.Remove(a.File.Name())
 	}
 }
+
+func TestAssetAvif(t *testing.T) {
+	vips.Startup(nil)
+	defer vips.Shutdown()
+
+	f, err := os.Open("../fixtures/cat.png")
+	assert.NoError(t, err)
+	defer f.Close()
+
+	a, err := New(f)
+	assert.NoError(t, err)
+	defer util.CleanupTempFile(a.File)
+
+	b, err := a.Resize(&ResizeParams{Width: 320, ImageType: vips.ImageTypeAVIF, Quality: 60, Effort: 4})
+	assert.NoError(t, err)
+	assert.Equal(t, AVIF, a.ContentType)
+	assert.Equal(t, ".avif", a.Ext)
+	assert.Equal(t, AVIF, DetectContentType(b))
+
+	// AVIF is also accepted as input
+	avif, err := New(bytes.NewReader(b))
+	assert.NoError(t, err)
+	defer util.CleanupTempFile(avif.File)
+	assert.Equal(t, IMAGE, avif.Type)
+
+	b, err = avif.Resize(&ResizeParams{Width: 160, ImageType: vips.ImageTypePNG})
+	assert.NoError(t, err)
+	assert.Equal(t, PNG, DetectContentType(b))
+}
+
+func TestAssetAnimated(t *testing.T) {
+	vips.Startup(nil)
+	defer vips.Shutdown()
+
+	f, err := os.Open("../fixtures/animated.gif")
+	assert.NoError(t, err)
+	defer f.Close()
+
+	a, err := New(f)
+	assert.NoError(t, err)
+	defer util.CleanupTempFile(a.File)
+	assert.Equal(t, GIF, a.ContentType)
+	assert.Equal(t, IMAGE, a.Type)
+
+	images := []struct {
+		rp     *ResizeParams
+		typ    string
+		frames int
+	}{
+		{&ResizeParams{Width: 32, ImageType: vips.ImageTypeGIF, Frame: AllFrames}, GIF, 3},
+		{&ResizeParams{Width: 32, ImageType: vips.ImageTypeWEBP, Frame: AllFrames}, WEBP, 3},
+		{&ResizeParams{Width: 32, ImageType: vips.ImageTypePNG, Frame: AllFrames}, PNG, 1},
+		{&ResizeParams{Width: 32, ImageType: vips.ImageTypeGIF, Frame: 1}, GIF, 1},
+	}
+
+	for _, img := range images {
+		b, err := a.Resize(img.rp)
+		assert.NoError(t, err)
+		assert.Equal(t, img.typ, a.ContentType)
+
+		p := vips.NewImportParams()
+		p.NumPages.Set(-1)
+		image, err := vips.LoadImageFromBuffer(b, p)
+		assert.NoError(t, err)
+		assert.Equal(t, 32, image.Width())
+		assert.Equal(t, 24, image.PageHeight())
+		assert.Equal(t, img.frames*24, image.Height())
+		image.Close()
+	}
+
+	_, err = a.Resize(&ResizeParams{ImageType: vips.ImageTypeGIF, Frame: 3})
+	assert.Equal(t, ErrFrameOutOfRange, err)
+}
+
+func TestDetectContentType(t *testing.T) {
+	ftyp := func(major string, compatible ...string) []byte {
+		b := []byte{0, 0, 0, byte(16 + 4*len(compatible))}
+		b = append(b, "ftyp"+major+"\x00\x00\x00\x00"...)
+		for _, c := range compatible {
+			b = append(b, c...)
+		}
+		return append(b, "\x00\x00\x00\x08mdat"...)
+	}
+
+	types := []struct {
+		buf []byte
+		typ string
+	}{
+		{ftyp("avif", "mif1", "avif"), AVIF},
+		{ftyp("heic", "mif1", "heic"), HEIC},
+		{ftyp("mif1", "mif1", "heic"), HEIC},
+		{ftyp("mif1", "mif1", "miaf", "avif"), AVIF},
+		{ftyp("mif1", "mif1"), HEIF},
+		{ftyp("msf1", "msf1"), HEIF},
+		{ftyp("isom", "isom", "mp41"), "video/mp4"},
+		{[]byte("GIF89a"), GIF},
+		{[]byte("II*\x00\x08\x00\x00\x00"), TIFF},
+		{[]byte("MM\x00*\x00\x00\x00\x08"), TIFF},
+		{[]byte("BM\x00\x00\x00\x00"), BMP},
+		{[]byte("\xff\x0a\x00\x00"), JXL},
+		{[]byte("\x00\x00\x00\x0cJXL \x0d\x0a\x87\x0a"), JXL},
+		{[]byte("plain text"), "text/plain; charset=utf-8"},
+	}
+
+	for _, typ := range types {
+		assert.Equal(t, typ.typ, DetectContentType(typ.buf))
+	}
+}
+
+func TestAssetTiff(t *testing.T) {
+	vips.Startup(nil)
+	defer vips.Shutdown()
+
+	f, err := os.Open("../fixtures/cat.png")
+	assert.NoError(t, err)
+	defer f.Close()
+
+	a, err := New(f)
+	assert.NoError(t, err)
+	defer util.CleanupTempFile(a.File)
+
+	b, err := a.Resize(&ResizeParams{
+		Width:       320,
+		ImageType:   vips.ImageTypeTIFF,
+		Compression: vips.TiffCompressionDeflate,
+		BitDepth:    16,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, TIFF, a.ContentType)
+	assert.Equal(t, ".tiff", a.Ext)
+	assert.Equal(t, TIFF, DetectContentType(b))
+
+	tiff, err := New(bytes.NewReader(b))
+	assert.NoError(t, err)
+	defer util.CleanupTempFile(tiff.File)
+	assert.Equal(t, IMAGE, tiff.Type)
+
+	image, err := vips.NewImageFromBuffer(b)
+	assert.NoError(t, err)
+	assert.Equal(t, vips.BandFormatUshort, image.BandFormat())
+	image.Close()
+
+	b, err = tiff.Resize(&ResizeParams{Width: 160, ImageType: vips.ImageTypePNG})
+	assert.NoError(t, err)
+	assert.Equal(t, PNG, DetectContentType(b))
+}
+
+func TestAssetSvg(t *testing.T) {
+	vips.Startup(nil)
+	defer vips.Shutdown()
+
+	f, err := os.Open("../fixtures/logo.svg")
+	assert.NoError(t, err)
+	defer f.Close()
+
+	a, err := New(f)
+	assert.NoError(t, err)
+	defer util.CleanupTempFile(a.File)
+	assert.NoError(t, a.Sanitize())
+
+	// rendered at a higher density rather than enlarged
+	b, err := a.Resize(&ResizeParams{Width: 512, ImageType: vips.ImageTypePNG})
+	assert.NoError(t, err)
+	assert.Equal(t, PNG, a.ContentType)
+
+	image, err := vips.NewImageFromBuffer(b)
+	assert.NoError(t, err)
+	assert.Equal(t, 512, image.Width())
+	assert.Equal(t, 512, image.Height())
+	image.Close()
+}
+
+func TestAssetPdf(t *testing.T) {
+	vips.Startup(nil)
+	defer vips.Shutdown()
+
+	f, err := os.Open("../fixtures/document.pdf")
+	assert.NoError(t, err)
+	defer f.Close()
+
+	a, err := New(f)
+	assert.NoError(t, err)
+	defer util.CleanupTempFile(a.File)
+	assert.Equal(t, PDF, a.ContentType)
+	assert.True(t, IsVector(a.ContentType))
+
+	m, err := a.Metadata()
+	assert.NoError(t, err)
+	assert.Equal(t, &Metadata{Width: 200, Height: 100, Pages: 2}, m)
+
+	b, err := a.Resize(&ResizeParams{Width: 400, ImageType: vips.ImageTypePNG, Frame: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, PNG, a.ContentType)
+
+	image, err := vips.NewImageFromBuffer(b)
+	assert.NoError(t, err)
+	assert.Equal(t, 400, image.Width())
+	assert.Equal(t, 200, image.Height())
+	image.Close()
+
+	_, err = a.Resize(&ResizeParams{ImageType: vips.ImageTypePNG, Frame: 2})
+	assert.Equal(t, ErrFrameOutOfRange, err)
+}
+
+func TestAssetFit(t *testing.T) {
+	vips.Startup(nil)
+	defer vips.Shutdown()
+
+	// cat.png is 1280x851
+	fits := []struct {
+		fit    Fit
+		width  int
+		height int
+	}{
+		{FitFill, 200, 200},
+		{FitCover, 200, 200},
+		{FitContain, 200, 200},
+		{FitInside, 200, 133},
+		{FitOutside, 301, 200},
+	}
+
+	for _, fit := range fits {
+		f, err := os.Open("../fixtures/cat.png")
+		assert.NoError(t, err)
+
+		a, err := New(f)
+		assert.NoError(t, err)
+
+		b, err := a.Resize(&ResizeParams{
+			Width:      200,
+			Height:     200,
+			ImageType:  vips.ImageTypePNG,
+			Fit:        fit.fit,
+			Background: vips.ColorRGBA{},
+		})
+		assert.NoError(t, err)
+
+		image, err := vips.NewImageFromBuffer(b)
+		assert.NoError(t, err)
+		assert.Equal(t, fit.width, image.Width(), FitTypesToString[fit.fit])
+		assert.Equal(t, fit.height, image.Height(), FitTypesToString[fit.fit])
+		if fit.fit == FitContain {
+			assert.True(t, image.HasAlpha())
+		}
+		image.Close()
+
+		f.Close()
+		util.CleanupTempFile(a.File)
+	}
+}
+
+func TestParseColor(t *testing.T) {
+	colors := []struct {
+		s     string
+		color vips.ColorRGBA
+		err   bool
+	}{
+		{"ff8000", vips.ColorRGBA{R: 255, G: 128, B: 0, A: 255}, false},
+		{"#FF8000", vips.ColorRGBA{R: 255, G: 128, B: 0, A: 255}, false},
+		{"#f80", vips.ColorRGBA{R: 255, G: 136, B: 0, A: 255}, false},
+		{"transparent", vips.ColorRGBA{}, false},
+		{"", vips.ColorRGBA{}, true},
+		{"red", vips.ColorRGBA{}, true},
+		{"ff80", vips.ColorRGBA{}, true},
+		{"ff800000", vips.ColorRGBA{}, true},
+	}
+
+	for _, color := range colors {
+		c, err := ParseColor(color.s)
+		assert.Equal(t, color.err, err != nil, color.s)
+		assert.Equal(t, color.color, c, color.s)
+	}
+}
+
+func TestAssetWithoutEnlargement(t *testing.T) {
+	vips.Startup(nil)
+	defer vips.Shutdown()
+
+	// cat.png is 1280x851
+	images := []struct {
+		rp     *ResizeParams
+		width  int
+		height int
+	}{
+		{&ResizeParams{Width: 4000}, 4000, 2659},
+		{&ResizeParams{Width: 4000, WithoutEnlargement: true}, 1280, 851},
+		{&ResizeParams{Width: 640, WithoutEnlargement: true}, 640, 426},
+		{&ResizeParams{Width: 4000, Height: 400, WithoutEnlargement: true}, 1280, 400},
+		{&ResizeParams{Width: 4000, Height: 4000, Fit: FitContain, WithoutEnlargement: true}, 4000, 4000},
+	}
+
+	for _, img := range images {
+		f, err := os.Open("../fixtures/cat.png")
+		assert.NoError(t, err)
+
+		a, err := New(f)
+		assert.NoError(t, err)
+
+		img.rp.ImageType = vips.ImageTypeJPEG
+		img.rp.Background = DefaultBackground
+		_, err = a.Resize(img.rp)
+		assert.NoError(t, err)
+		assert.Equal(t, img.width, a.Width)
+		assert.Equal(t, img.height, a.Height)
+
+		f.Close()
+		util.CleanupTempFile(a.File)
+	}
+}
+
+func TestAssetCrop(t *testing.T) {
+	vips.Startup(nil)
+	defer vips.Shutdown()
+
+	px := func(v float64) Length { return Length{Value: v} }
+	pct := func(v float64) Length { return Length{Value: v, Percent: true} }
+
+	// cat.png is 1280x851
+	images := []struct {
+		rp     *ResizeParams
+		width  int
+		height int
+	}{
+		{&ResizeParams{Crop: Region{px(0), px(0), px(640), px(400)}}, 640, 400},
+		{&ResizeParams{Crop: Region{pct(50), pct(50), pct(50), pct(50)}}, 640, 425},
+		{&ResizeParams{Width: 200, Crop: Region{px(0), px(0), px(400), px(400)}}, 200, 200},
+		{&ResizeParams{Crop: Region{px(1200), px(0), px(400), px(100)}}, 80, 100},
+		{&ResizeParams{Width: 200, Height: 200, Fit: FitCover, FocalPoint: FocalPoint{X: 1, Y: 0.5},
+			UseFocalPoint: true}, 200, 200},
+	}
+
+	for _, img := range images {
+		f, err := os.Open("../fixtures/cat.png")
+		assert.NoError(t, err)
+
+		a, err := New(f)
+		assert.NoError(t, err)
+
+		img.rp.ImageType = vips.ImageTypePNG
+		_, err = a.Resize(img.rp)
+		assert.NoError(t, err)
+		assert.Equal(t, img.width, a.Width)
+		assert.Equal(t, img.height, a.Height)
+
+		f.Close()
+		util.CleanupTempFile(a.File)
+	}
+
+	f, err := os.Open("../fixtures/cat.png")
+	assert.NoError(t, err)
+	defer f.Close()
+
+	a, err := New(f)
+	assert.NoError(t, err)
+	defer util.CleanupTempFile(a.File)
+
+	_, err = a.Resize(&ResizeParams{ImageType: vips.ImageTypePNG, Crop: Region{px(1280), px(0), px(10), px(10)}})
+	assert.Equal(t, ErrCropOutOfBounds, err)
+}
+
+func TestAssetCropMultiPage(t *testing.T) {
+	vips.Startup(nil)
+	defer vips.Shutdown()
+
+	px := func(v float64) Length { return Length{Value: v} }
+
+	// animated.gif is 64x48 with 3 frames, the pages of document.pdf are 200x100
+	images := []struct {
+		file   string
+		rp     *ResizeParams
+		width  int
+		height int
+	}{
+		{"animated.gif", &ResizeParams{Frame: AllFrames, Crop: Region{px(0), px(0), px(32), px(24)}}, 32, 24},
+		{"animated.gif", &ResizeParams{Frame: 1, Crop: Region{px(0), px(0), px(32), px(24)}}, 32, 24},
+		{"animated.gif", &ResizeParams{Frame: AllFrames, Width: 32, Height: 32, Fit: FitCover,
+			FocalPoint: FocalPoint{X: 1, Y: 0.5}, UseFocalPoint: true}, 32, 32},
+		{"document.pdf", &ResizeParams{Frame: 1, Crop: Region{px(0), px(0), px(100), px(50)}}, 100, 50},
+		{"document.pdf", &ResizeParams{Frame: 1, Width: 50, Height: 50, Fit: FitCover,
+			FocalPoint: FocalPoint{X: 0, Y: 0.5}, UseFocalPoint: true}, 50, 50},
+	}
+
+	for _, img := range images {
+		f, err := os.Open("../fixtures/" + img.file)
+		assert.NoError(t, err)
+
+		a, err := New(f)
+		assert.NoError(t, err)
+
+		img.rp.ImageType = vips.ImageTypePNG
+		_, err = a.Resize(img.rp)
+		assert.NoError(t, err, img.file)
+		assert.Equal(t, img.width, a.Width, img.file)
+		assert.Equal(t, img.height, a.Height, img.file)
+
+		f.Close()
+		util.CleanupTempFile(a.File)
+	}
+}
+
+func TestFocus(t *testing.T) {
+	assert.Equal(t, 50, focus(0.5, 300, 200))
+	assert.Equal(t, 0, focus(0, 300, 200))
+	assert.Equal(t, 100, focus(1, 300, 200))
+	assert.Equal(t, 0, focus(0.5, 200, 200))
+}
+
+func TestParseRegion(t *testing.T) {
+	r, err := ParseRegion("10,20%,300,50%")
+	assert.NoError(t, err)
+	assert.Equal(t, Region{
+		Left:   Length{Value: 10},
+		Top:    Length{Value: 20, Percent: true},
+		Width:  Length{Value: 300},
+		Height: Length{Value: 50, Percent: true},
+	}, r)
+
+	for _, s := range []string{"", "1,2,3", "1,2,3,4,5", "a,0,10,10", "-1,0,10,10", "0,0,101%,10", "0,0,0,10",
+		"0,0,NaN%,10", "1.5,0,10,10"} {
+		_, err := ParseRegion(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestParseFocalPoint(t *testing.T) {
+	fp, err := ParseFocalPoint("0.3,0.6")
+	assert.NoError(t, err)
+	assert.Equal(t, FocalPoint{X: 0.3, Y: 0.6}, fp)
+
+	for _, s := range []string{"", "0.3", "0.3,0.6,1", "a,0", "1.1,0", "0,-0.1", "NaN,0"} {
+		_, err := ParseFocalPoint(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestAssetRotate(t *testing.T) {
+	vips.Startup(nil)
+	defer vips.Shutdown()
+
+	// cat.jpg is 1280x851, cat_rotated.jpg is the same with an EXIF orientation of 6
+	images := []struct {
+		file   string
+		rp     *ResizeParams
+		width  int
+		height int
+	}{
+		{"cat_rotated.jpg", &ResizeParams{}, 851, 1280},
+		{"cat_rotated.jpg", &ResizeParams{Width: 200}, 200, 301},
+		{"cat.jpg", &ResizeParams{Rotate: 90}, 851, 1280},
+		{"cat.jpg", &ResizeParams{Rotate: 180, Flip: FlipVertical}, 1280, 851},
+		{"cat.jpg", &ResizeParams{Flip: FlipHorizontal}, 1280, 851},
+		{"cat.jpg", &ResizeParams{Rotate: 90, Width: 200, Height: 100}, 200, 100},
+		{"cat.jpg", &ResizeParams{Rotate: 45, Background: DefaultBackground}, 1507, 1507},
+	}
+
+	for _, img := range images {
+		f, err := os.Open("../fixtures/" + img.file)
+		assert.NoError(t, err)
+
+		a, err := New(f)
+		assert.NoError(t, err)
+
+		img.rp.ImageType = vips.ImageTypeJPEG
+		_, err = a.Resize(img.rp)
+		assert.NoError(t, err)
+		// rotating by angles that aren't a multiple of 90 rounds the size
+		assert.InDelta(t, img.width, a.Width, 1, img.file)
+		assert.InDelta(t, img.height, a.Height, 1, img.file)
+
+		f.Close()
+		util.CleanupTempFile(a.File)
+	}
+
+	f, err := os.Open("../fixtures/cat_rotated.jpg")
+	assert.NoError(t, err)
+	defer f.Close()
+
+	a, err := New(f)
+	assert.NoError(t, err)
+	defer util.CleanupTempFile(a.File)
+
+	m, err := a.Metadata()
+	assert.NoError(t, err)
+	assert.Equal(t, 851, m.Width)
+	assert.Equal(t, 1280, m.Height)
+}
+
+func TestAssetRotateAnimated(t *testing.T) {
+	vips.Startup(nil)
+	defer vips.Shutdown()
+
+	f, err := os.Open("../fixtures/animated.gif")
+	assert.NoError(t, err)
+	defer f.Close()
+
+	a, err := New(f)
+	assert.NoError(t, err)
+	defer util.CleanupTempFile(a.File)
+
+	// animated.gif is 64x48 with 3 frames
+	images := []struct {
+		rp     *ResizeParams
+		width  int
+		height int
+		frames int
+	}{
+		{&ResizeParams{Rotate: 90}, 48, 64, 3},
+		{&ResizeParams{Rotate: 180}, 64, 48, 3},
+		{&ResizeParams{Flip: FlipVertical}, 64, 48, 3},
+		{&ResizeParams{Rotate: 30, Background: DefaultBackground}, 80, 74, 1},
+	}
+
+	for _, img := range images {
+		img.rp.ImageType = vips.ImageTypeGIF
+		img.rp.Frame = AllFrames
+		b, err := a.Resize(img.rp)
+		assert.NoError(t, err)
+
+		p := vips.NewImportParams()
+		p.NumPages.Set(-1)
+		image, err := vips.LoadImageFromBuffer(b, p)
+		assert.NoError(t, err)
+		assert.InDelta(t, img.width, image.Width(), 1)
+		assert.InDelta(t, img.height, image.PageHeight(), 1)
+		assert.Equal(t, img.frames, image.Height()/image.PageHeight())
+		image.Close()
+	}
+}
+
+func TestAssetRotateMultiPage(t *testing.T) {
+	vips.Startup(nil)
+	defer vips.Shutdown()
+
+	// animated.gif is 64x48 with 3 frames, the pages of document.pdf are 200x100
+	images := []struct {
+		file   string
+		rp     *ResizeParams
+		width  int
+		height int
+	}{
+		{"animated.gif", &ResizeParams{Frame: AllFrames, Rotate: 90}, 48, 64},
+		{"animated.gif", &ResizeParams{Frame: 2, Rotate: 270}, 48, 64},
+		{"document.pdf", &ResizeParams{Frame: 0, Rotate: 90}, 100, 200},
+		{"document.pdf", &ResizeParams{Frame: 1, Rotate: 270}, 100, 200},
+	}
+
+	for _, img := range images {
+		f, err := os.Open("../fixtures/" + img.file)
+		assert.NoError(t, err)
+
+		a, err := New(f)
+		assert.NoError(t, err)
+
+		img.rp.ImageType = vips.ImageTypePNG
+		_, err = a.Resize(img.rp)
+		assert.NoError(t, err, img.file)
+		assert.Equal(t, img.width, a.Width, img.file)
+		assert.Equal(t, img.height, a.Height, img.file)
+
+		f.Close()
+		util.CleanupTempFile(a.File)
+	}
+}
+
+func TestAssetFilters(t *testing.T) {
+	vips.Startup(nil)
+	defer vips.Shutdown()
+
+	f, err := os.Open("../fixtures/cat.jpg")
+	assert.NoError(t, err)
+	defer f.Close()
+
+	a, err := New(f)
+	assert.NoError(t, err)
+	defer util.CleanupTempFile(a.File)
+
+	red := vips.ColorRGBA{R: 255, A: 255}
+	filters := []*ResizeParams{
+		{Grayscale: true},
+		{Brightness: 50, Contrast: -20, Saturation: 30},
+		{Brightness: -100},
+		{Gamma: 2.2},
+		{Tint: red},
+		{Grayscale: true, Tint: red},
+		{Blur: 5},
+		{Sharpen: 1},
+		{Grayscale: true, Brightness: 10, Contrast: 10, Saturation: 10, Gamma: 0.5, Tint: red, Blur: 1, Sharpen: 1},
+	}
+
+	for _, rp := range filters {
+		rp.Width = 64
+		rp.ImageType = vips.ImageTypePNG
+		b, err := a.Resize(rp)
+		assert.NoError(t, err, "%+v", rp)
+
+		image, err := vips.NewImageFromBuffer(b)
+		assert.NoError(t, err)
+		assert.Equal(t, 64, image.Width())
+
+		p, err := image.GetPoint(32, 20)
+		assert.NoError(t, err)
+		switch {
+		case rp.Brightness == -100:
+			assert.Equal(t, 0.0, p[0])
+		case rp.Tint == red:
+			assert.Equal(t, 0.0, p[1])
+			assert.Equal(t, 0.0, p[2])
+		case rp.Grayscale:
+			assert.Equal(t, vips.InterpretationBW, image.Interpretation())
+		}
+		image.Close()
+	}
+}
+
+func TestGammaLUT(t *testing.T) {
+	vips.Startup(nil)
+	defer vips.Shutdown()
+
+	for _, ushort := range []bool{false, true} {
+		lut, release, err := getGammaLUT(2.2, ushort)
+		assert.NoError(t, err)
+		release()
+
+		// it's built once per gamma and bit depth
+		cached, release, err := getGammaLUT(2.2, ushort)
+		assert.NoError(t, err)
+		assert.Same(t, lut, cached)
+		release()
+
+		if ushort {
+			assert.Equal(t, 65536, lut.Width())
+		} else {
+			assert.Equal(t, 256, lut.Width())
+		}
+	}
+}
+
+func TestOverlayPosition(t *testing.T) {
+	positions := []struct {
+		overlay Overlay
+		left    int
+		top     int
+	}{
+		{Overlay{}, 40, 25},
+		{Overlay{X: 5, Y: -5}, 45, 20},
+		{Overlay{Gravity: GravityNorthWest, X: 5, Y: 5}, 5, 5},
+		{Overlay{Gravity: GravitySouthEast, X: 5, Y: 5}, 75, 45},
+		{Overlay{Gravity: GravityNorth}, 40, 0},
+		{Overlay{Gravity: GravityEast}, 80, 25},
+	}
+
+	for _, p := range positions {
+		left, top := p.overlay.position(100, 60, 20, 10)
+		assert.Equal(t, p.left, left, "%+v", p.overlay)
+		assert.Equal(t, p.top, top, "%+v", p.overlay)
+	}
+}
+
+func TestAssetOverlay(t *testing.T) {
+	vips.Startup(nil)
+	defer vips.Shutdown()
+
+	overlay, err := os.ReadFile("../fixtures/cat_640.png")
+	assert.NoError(t, err)
+
+	images := []struct {
+		file   string
+		frames int
+	}{
+		{"cat.jpg", 1},
+		{"animated.gif", 3},
+	}
+
+	for _, img := range images {
+		f, err := os.Open("../fixtures/" + img.file)
+		assert.NoError(t, err)
+
+		a, err := New(f)
+		assert.NoError(t, err)
+
+		rp := NewResizeParams()
+		rp.Width = 64
+		rp.ImageType = vips.ImageTypeGIF
+		rp.Overlay = Overlay{Id: "overlay", Gravity: GravitySouthEast, Opacity: 0.5, Scale: 0.25}
+		rp.OverlayImage = overlay
+		b, err := a.Resize(rp)
+		assert.NoError(t, err)
+
+		p := vips.NewImportParams()
+		p.NumPages.Set(-1)
+		image, err := vips.LoadImageFromBuffer(b, p)
+		assert.NoError(t, err)
+		assert.Equal(t, 64, image.Width())
+		assert.Equal(t, img.frames, image.Height()/image.PageHeight())
+		image.Close()
+
+		f.Close()
+		util.CleanupTempFile(a.File)
+	}
+
+	// the image itself isn't part of the hash
+	rp := NewResizeParams()
+	rp.Overlay.Id = "overlay"
+	hash := rp.Hash()
+	rp.OverlayImage = overlay
+	assert.Equal(t, hash, rp.Hash())
+}
+
+func TestAssetText(t *testing.T) {
+	fonts, err := LoadFonts("../fixtures/fonts")
+	assert.NoError(t, err)
+
+	vips.Startup(nil)
+	defer vips.Shutdown()
+
+	images := []struct {
+		file   string
+		frames int
+	}{
+		{"cat.jpg", 1},
+		{"animated.gif", 3},
+	}
+
+	for _, img := range images {
+		f, err := os.Open("../fixtures/" + img.file)
+		assert.NoError(t, err)
+
+		a, err := New(f)
+		assert.NoError(t, err)
+
+		rp := NewResizeParams()
+		rp.Width = 64
+		rp.ImageType = vips.ImageTypeGIF
+		plain, err := a.Resize(rp)
+		assert.NoError(t, err)
+
+		// text wider than the image wraps instead of enlarging it
+		rp.Text = Text{
+			Text:    "<air> & a long line of text",
+			Font:    fonts["DejaVuSansMono"],
+			Size:    16,
+			Color:   vips.ColorRGBA{R: 255, A: 255},
+			Gravity: GravitySouth,
+		}
+		b, err := a.Resize(rp)
+		assert.NoError(t, err)
+		assert.NotEqual(t, plain, b)
+
+		p := vips.NewImportParams()
+		p.NumPages.Set(-1)
+		image, err := vips.LoadImageFromBuffer(b, p)
+		assert.NoError(t, err)
+		assert.Equal(t, 64, image.Width())
+		assert.Equal(t, img.frames, image.Height()/image.PageHeight())
+		image.Close()
+
+		f.Close()
+		util.CleanupTempFile(a.File)
+	}
+}
+
+func TestLoadFonts(t *testing.T) {
+	fonts, err := LoadFonts("../fixtures/fonts")
+	assert.NoError(t, err)
+	assert.Equal(t, Fonts{"DejaVuSansMono": "DejaVu Sans Mono Book"}, fonts)
+
+	_, err = LoadFonts("../fixtures/missing")
+	assert.Error(t, err)
+}
@@ -0,0 +1,234 @@
+package asset
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"sync"
+
+	"github.com/davidbyttow/govips/v2/vips"
+)
+
+const (
+	// sharpenFlat and sharpenJaggy are how much flat and jaggy areas are sharpened
+	sharpenFlat  = 2
+	sharpenJaggy = 3
+)
+
+// applyFilters adjusts img as rp asks, always in the same order: grayscale,
+// brightness, contrast and saturation, gamma, tint, blur then sharpen
+func applyFilters(img *vips.ImageRef, rp *ResizeParams) error {
+	if rp.Grayscale {
+		interpretation := vips.InterpretationBW
+		if img.BandFormat() == vips.BandFormatUshort {
+			interpretation = vips.InterpretationGrey16
+		}
+		err := img.ToColorSpace(interpretation)
+		if err != nil {
+			return err
+		}
+	}
+
+	if rp.Brightness != 0 || rp.Contrast != 0 || rp.Saturation != 0 {
+		err := adjust(img, rp.Brightness, rp.Contrast, rp.Saturation)
+		if err != nil {
+			return err
+		}
+	}
+
+	if rp.Gamma > 0 && rp.Gamma != 1 {
+		err := gamma(img, rp.Gamma)
+		if err != nil {
+			return err
+		}
+	}
+
+	if rp.Tint.A > 0 {
+		err := tint(img, rp.Tint)
+		if err != nil {
+			return err
+		}
+	}
+
+	if rp.Blur > 0 {
+		err := img.GaussianBlur(rp.Blur)
+		if err != nil {
+			return err
+		}
+	}
+
+	if rp.Sharpen > 0 {
+		return img.Sharpen(rp.Sharpen, sharpenFlat, sharpenJaggy)
+	}
+
+	return nil
+}
+
+// adjust changes the lightness and chroma of img, each by -100 to 100 percent.
+// Contrast stretches the lightness away from the middle grey.
+func adjust(img *vips.ImageRef, brightness int, contrast int, saturation int) error {
+	colorspace := img.ColorSpace()
+	if colorspace == vips.InterpretationRGB {
+		colorspace = vips.InterpretationSRGB
+	}
+
+	b := 1 + float64(brightness)/100
+	c := 1 + float64(contrast)/100
+	s := 1 + float64(saturation)/100
+
+	// lightness is between 0 and 100
+	multiplications := []float64{b * c, s, 1}
+	additions := []float64{50 * (1 - c), 0, 0}
+	if img.HasAlpha() {
+		multiplications = append(multiplications, 1)
+		additions = append(additions, 0)
+	}
+
+	err := img.ToColorSpace(vips.InterpretationLCH)
+	if err != nil {
+		return err
+	}
+
+	err = img.Linear(multiplications, additions)
+	if err != nil {
+		return err
+	}
+
+	return img.ToColorSpace(colorspace)
+}
+
+// gamma brightens img for values of g above 1 and darkens it below, alpha is kept
+func gamma(img *vips.ImageRef, g float64) error {
+	lut, release, err := getGammaLUT(g, img.BandFormat() == vips.BandFormatUshort)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if !img.HasAlpha() {
+		return img.Maplut(lut)
+	}
+
+	alpha, err := img.Copy()
+	if err != nil {
+		return err
+	}
+	defer alpha.Close()
+
+	bands := img.Bands()
+	err = alpha.ExtractBand(bands-1, 1)
+	if err != nil {
+		return err
+	}
+	err = img.ExtractBand(0, bands-1)
+	if err != nil {
+		return err
+	}
+	err = img.Maplut(lut)
+	if err != nil {
+		return err
+	}
+	return img.BandJoin(alpha)
+}
+
+// maxGammaLUTs is how many lookup tables are kept, tables for other gammas are
+// built for each image
+const maxGammaLUTs = 64
+
+type gammaLUTKey struct {
+	g      float64
+	ushort bool
+}
+
+// gammaLUTs holds the lookup tables built for each gamma and bit depth, they're
+// only read so every image shares them. govips has no pow to make them from an
+// identity image.
+var gammaLUTs = struct {
+	sync.Mutex
+	luts map[gammaLUTKey]*vips.ImageRef
+}{luts: map[gammaLUTKey]*vips.ImageRef{}}
+
+// getGammaLUT returns the lookup table for the gamma g and a func to call once
+// it's no longer used
+func getGammaLUT(g float64, ushort bool) (*vips.ImageRef, func(), error) {
+	key := gammaLUTKey{g, ushort}
+	keep := func() {}
+
+	gammaLUTs.Lock()
+	lut, ok := gammaLUTs.luts[key]
+	gammaLUTs.Unlock()
+	if ok {
+		return lut, keep, nil
+	}
+
+	lut, err := gammaLUT(g, ushort)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	gammaLUTs.Lock()
+	defer gammaLUTs.Unlock()
+	if cached, ok := gammaLUTs.luts[key]; ok {
+		lut.Close()
+		return cached, keep, nil
+	}
+	if len(gammaLUTs.luts) >= maxGammaLUTs {
+		return lut, lut.Close, nil
+	}
+	gammaLUTs.luts[key] = lut
+	return lut, keep, nil
+}
+
+// gammaLUT returns a lookup table mapping 8 or 16 bit values through the gamma g
+func gammaLUT(g float64, ushort bool) (*vips.ImageRef, error) {
+	var lut image.Image
+	if ushort {
+		gray := image.NewGray16(image.Rect(0, 0, 65536, 1))
+		for i := 0; i < 65536; i++ {
+			gray.SetGray16(i, 0, color.Gray16{Y: uint16(math.Round(math.Pow(float64(i)/65535, 1/g) * 65535))})
+		}
+		lut = gray
+	} else {
+		gray := image.NewGray(image.Rect(0, 0, 256, 1))
+		for i := 0; i < 256; i++ {
+			gray.SetGray(i, 0, color.Gray{Y: uint8(math.Round(math.Pow(float64(i)/255, 1/g) * 255))})
+		}
+		lut = gray
+	}
+
+	var buf bytes.Buffer
+	err := png.Encode(&buf, lut)
+	if err != nil {
+		return nil, err
+	}
+	return vips.NewImageFromBuffer(buf.Bytes())
+}
+
+// tint multiplies the red, green and blue bands of img by those of c, like
+// looking through a colored filter. Grayscale images become shades of c.
+func tint(img *vips.ImageRef, c vips.ColorRGBA) error {
+	if img.Bands() < 3 {
+		err := img.ToColorSpace(vips.InterpretationSRGB)
+		if err != nil {
+			return err
+		}
+	}
+	format := img.BandFormat()
+
+	multiplications := []float64{float64(c.R) / 255, float64(c.G) / 255, float64(c.B) / 255}
+	additions := []float64{0, 0, 0}
+	if img.HasAlpha() {
+		multiplications = append(multiplications, 1)
+		additions = append(additions, 0)
+	}
+
+	err := img.Linear(multiplications, additions)
+	if err != nil {
+		return err
+	}
+
+	// Linear makes floats
+	return img.Cast(format)
+}
@@ -0,0 +1,118 @@
+package asset
+
+import (
+	"github.com/davidbyttow/govips/v2/vips"
+)
+
+// Gravity is the part of an image an overlay is placed on
+type Gravity int
+
+const (
+	GravityCentre Gravity = iota
+	GravityNorth
+	GravityNorthEast
+	GravityEast
+	GravitySouthEast
+	GravitySouth
+	GravitySouthWest
+	GravityWest
+	GravityNorthWest
+)
+
+// Overlay is an image composited over resized images, such as a watermark
+type Overlay struct {
+	// Id identifies the image in cache keys, the image itself is in ResizeParams.OverlayImage
+	Id      string
+	Gravity Gravity
+	// X and Y move the overlay in pixels away from the edges it's placed against,
+	// or right and down from the centre
+	X int
+	Y int
+	// Opacity is between 0 and 1
+	Opacity float64
+	// Scale is the width of the overlay relative to the image's, 0 keeps its size
+	Scale float64
+}
+
+// position returns where the top left corner of an overlay width by height
+// pixels is placed on an image imageWidth by imageHeight pixels
+func (o Overlay) position(imageWidth int, imageHeight int, width int, height int) (int, int) {
+	return position(o.Gravity, o.X, o.Y, imageWidth, imageHeight, width, height)
+}
+
+// position returns where the top left corner of something width by height
+// pixels is placed with g, x pixels across and y down from the edges or centre
+func position(g Gravity, x int, y int, imageWidth int, imageHeight int, width int, height int) (int, int) {
+	left := (imageWidth-width)/2 + x
+	switch g {
+	case GravityNorthWest, GravityWest, GravitySouthWest:
+		left = x
+	case GravityNorthEast, GravityEast, GravitySouthEast:
+		left = imageWidth - width - x
+	}
+
+	top := (imageHeight-height)/2 + y
+	switch g {
+	case GravityNorthWest, GravityNorth, GravityNorthEast:
+		top = y
+	case GravitySouthWest, GravitySouth, GravitySouthEast:
+		top = imageHeight - height - y
+	}
+
+	return left, top
+}
+
+// composite places the overlay in rp over every frame of image
+func composite(image *vips.ImageRef, rp *ResizeParams) error {
+	overlay, err := vips.NewImageFromBuffer(rp.OverlayImage)
+	if err != nil {
+		return err
+	}
+	defer overlay.Close()
+
+	if rp.Overlay.Scale > 0 {
+		err = overlay.Resize(rp.Overlay.Scale*float64(image.Width())/float64(overlay.Width()), vips.KernelAuto)
+		if err != nil {
+			return err
+		}
+	}
+
+	// the opacity is applied to the alpha of red, green and blue overlays
+	if overlay.Bands() < 3 {
+		err = overlay.ToColorSpace(vips.InterpretationSRGB)
+		if err != nil {
+			return err
+		}
+	}
+	if !overlay.HasAlpha() {
+		err = overlay.AddAlpha()
+		if err != nil {
+			return err
+		}
+	}
+	if rp.Overlay.Opacity < 1 {
+		err = overlay.Linear([]float64{1, 1, 1, rp.Overlay.Opacity}, []float64{0, 0, 0, 0})
+		if err != nil {
+			return err
+		}
+	}
+
+	left, top := rp.Overlay.position(image.Width(), image.PageHeight(), overlay.Width(), overlay.Height())
+	return compositeFrames(image, overlay, left, top)
+}
+
+// compositeFrames places overlay left and top pixels into every frame of image
+func compositeFrames(image *vips.ImageRef, overlay *vips.ImageRef, left int, top int) error {
+	pageHeight := image.PageHeight()
+	var frames []*vips.ImageComposite
+	for y := 0; y < image.Height(); y += pageHeight {
+		frames = append(frames, &vips.ImageComposite{
+			Image:     overlay,
+			BlendMode: vips.BlendModeOver,
+			X:         left,
+			Y:         y + top,
+		})
+	}
+
+	return image.CompositeMulti(frames)
+}
@@ -0,0 +1,209 @@
+package asset
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// svgNamespace is the namespace of SVG elements
+const svgNamespace = "http://www.w3.org/2000/svg"
+
+// ErrInvalidSVG is returned when an SVG can't be parsed for sanitizing
+var ErrInvalidSVG = errors.New("invalid SVG")
+
+// svgForbiddenElements are removed from SVGs along with their content
+var svgForbiddenElements = map[string]bool{
+	"script":        true,
+	"foreignobject": true,
+	"iframe":        true,
+	"embed":         true,
+	"object":        true,
+	"handler":       true,
+	"listener":      true,
+}
+
+// svgAnimationElements can change other attributes, they're removed when they target links
+var svgAnimationElements = map[string]bool{
+	"set":              true,
+	"animate":          true,
+	"animatemotion":    true,
+	"animatetransform": true,
+}
+
+var (
+	cssImport = regexp.MustCompile(`(?i)@import[^;]*;?`)
+	cssURL    = regexp.MustCompile(`(?i)url\(\s*(['"]?)\s*([^'")]*?)\s*(['"]?)\s*\)`)
+	dataImage = regexp.MustCompile(`^data:image/(png|jpeg|gif|webp)[;,]`)
+)
+
+var (
+	textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;",
+		"\t", "&#x9;", "\n", "&#xA;", "\r", "&#xD;")
+)
+
+// isSVG reports whether the root element of the XML document in r is an svg
+// element or in the SVG namespace, however long the XML declaration, comments
+// and doctype before it are
+func isSVG(r io.Reader) bool {
+	br := bufio.NewReader(r)
+	if bom, err := br.Peek(3); err == nil && string(bom) == "\xef\xbb\xbf" {
+		_, _ = br.Discard(3)
+	}
+
+	d := xml.NewDecoder(br)
+	// element names are ASCII whichever encoding is declared
+	d.CharsetReader = func(_ string, r io.Reader) (io.Reader, error) { return r, nil }
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return false
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			return t.Name.Local == "svg" || t.Name.Space == svgNamespace
+		case xml.CharData:
+			if len(bytes.TrimSpace(t)) > 0 {
+				return false
+			}
+		}
+	}
+}
+
+// sanitizeSVG copies the SVG in r to w without scripts, event handlers and
+// references to external resources. Comments, processing instructions and
+// doctypes are dropped so entities can't be declared.
+func sanitizeSVG(w io.Writer, r io.Reader) error {
+	d := xml.NewDecoder(r)
+
+	var depth, skip int
+	var inStyle bool
+	for {
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSVG, err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if skip > 0 || isForbiddenElement(t) {
+				skip++
+				continue
+			}
+			depth++
+			inStyle = strings.EqualFold(t.Name.Local, "style")
+			err = writeStartElement(w, t)
+		case xml.EndElement:
+			if skip > 0 {
+				skip--
+				continue
+			}
+			depth--
+			inStyle = false
+			_, err = fmt.Fprintf(w, "</%s>", qualifiedName(t.Name))
+		case xml.CharData:
+			// text outside the root element is only whitespace
+			if skip > 0 || depth == 0 {
+				continue
+			}
+			text := string(t)
+			if inStyle {
+				text = sanitizeCSS(text)
+			}
+			_, err = textEscaper.WriteString(w, text)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func isForbiddenElement(t xml.StartElement) bool {
+	name := strings.ToLower(t.Name.Local)
+	if svgForbiddenElements[name] {
+		return true
+	}
+	if svgAnimationElements[name] {
+		for _, attr := range t.Attr {
+			if attr.Name.Local == "attributeName" && strings.HasSuffix(strings.ToLower(attr.Value), "href") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func writeStartElement(w io.Writer, t xml.StartElement) error {
+	_, err := fmt.Fprintf(w, "<%s", qualifiedName(t.Name))
+	if err != nil {
+		return err
+	}
+
+	for _, attr := range t.Attr {
+		value, ok := sanitizeAttr(attr)
+		if !ok {
+			continue
+		}
+		_, err = fmt.Fprintf(w, ` %s="%s"`, qualifiedName(attr.Name), attrEscaper.Replace(value))
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = io.WriteString(w, ">")
+	return err
+}
+
+// sanitizeAttr returns the value attr is kept with, if it's kept at all
+func sanitizeAttr(attr xml.Attr) (string, bool) {
+	name := strings.ToLower(attr.Name.Local)
+	if attr.Name.Space == "" && strings.HasPrefix(name, "on") {
+		return "", false
+	}
+
+	compact := strings.ToLower(strings.Join(strings.Fields(attr.Value), ""))
+	if strings.Contains(compact, "javascript:") {
+		return "", false
+	}
+
+	if name == "href" || name == "src" {
+		if !isLocalReference(strings.TrimSpace(attr.Value)) {
+			return "", false
+		}
+	}
+
+	return sanitizeCSS(attr.Value), true
+}
+
+// sanitizeCSS removes imports and replaces external url() references with none
+func sanitizeCSS(css string) string {
+	css = cssImport.ReplaceAllString(css, "")
+	return cssURL.ReplaceAllStringFunc(css, func(s string) string {
+		m := cssURL.FindStringSubmatch(s)
+		if isLocalReference(m[2]) {
+			return s
+		}
+		return "none"
+	})
+}
+
+// isLocalReference reports whether ref points inside the document or embeds a raster image
+func isLocalReference(ref string) bool {
+	return strings.HasPrefix(ref, "#") || dataImage.MatchString(strings.ToLower(ref))
+}
+
+func qualifiedName(n xml.Name) string {
+	if n.Space == "" {
+		return n.Local
+	}
+	return n.Space + ":" + n.Local
+}
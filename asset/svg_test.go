@@ -0,0 +1,113 @@
+package asset
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/alexferl/air/util"
+)
+
+func TestIsSVG(t *testing.T) {
+	svgs := []struct {
+		buf string
+		svg bool
+	}{
+		{`<svg xmlns="http://www.w3.org/2000/svg"></svg>`, true},
+		{"\xef\xbb\xbf  <svg>", true},
+		{`<?xml version="1.0"?><!-- logo --><svg/>`, true},
+		{`<?xml version="1.0"?><!DOCTYPE svg><svg width="1">`, true},
+		{`<svgfoo>`, false},
+		{`<html><svg></svg></html>`, false},
+		{`<?xml version="1.0"?><feed></feed>`, false},
+		{`svg`, false},
+		{`<?xml version="1.0" encoding="ISO-8859-1"?><svg/>`, true},
+		{`<x:svg xmlns:x="http://www.w3.org/2000/svg"/>`, true},
+		{`<script xmlns="http://www.w3.org/2000/svg">alert(1)</script>`, true},
+		{`<?xml version="1.0"?><!--` + strings.Repeat(" ", 4096) + `--><svg/>`, true},
+	}
+
+	for _, svg := range svgs {
+		assert.Equal(t, svg.svg, isSVG(strings.NewReader(svg.buf)), svg.buf)
+	}
+}
+
+func TestSanitizeSVG(t *testing.T) {
+	svgs := []struct {
+		in  string
+		out string
+	}{
+		{`<svg><circle r="1"/></svg>`, `<svg><circle r="1"></circle></svg>`},
+		{"\n<svg>\n  <text x=\"1\">a &lt; b</text>\n</svg>\n", "<svg>\n  <text x=\"1\">a &lt; b</text>\n</svg>"},
+		{`<svg onload="alert(1)" width="1"></svg>`, `<svg width="1"></svg>`},
+		{`<svg><script>alert(1)</script><g><SCRIPT/></g></svg>`, `<svg><g></g></svg>`},
+		{`<svg><foreignObject><div><p>x</p></div></foreignObject></svg>`, `<svg></svg>`},
+		{`<svg><a xlink:href="java&#x0A;script:alert(1)">x</a></svg>`, `<svg><a>x</a></svg>`},
+		{`<svg><use xlink:href="#a"/><image href="http://example.com/a.png"/></svg>`,
+			`<svg><use xlink:href="#a"></use><image></image></svg>`},
+		{`<svg><image href="data:image/png;base64,AA=="/></svg>`,
+			`<svg><image href="data:image/png;base64,AA=="></image></svg>`},
+		{`<svg><image href="data:image/svg+xml;base64,AA=="/></svg>`, `<svg><image></image></svg>`},
+		{`<svg><set attributeName="href" to="javascript:alert(1)"/></svg>`, `<svg></svg>`},
+		{`<svg><rect fill="url(http://example.com/#a)" stroke="url(#b)"/></svg>`,
+			`<svg><rect fill="none" stroke="url(#b)"></rect></svg>`},
+		{`<svg><style>@import "http://example.com/a.css"; a { b: url('//example.com') }</style></svg>`,
+			`<svg><style> a { b: none }</style></svg>`},
+		{`<?xml version="1.0"?><!DOCTYPE svg [<!ENTITY a "b">]><!-- c --><svg>&amp;</svg>`, `<svg>&amp;</svg>`},
+	}
+
+	for _, svg := range svgs {
+		var buf bytes.Buffer
+		assert.NoError(t, sanitizeSVG(&buf, strings.NewReader(svg.in)))
+		assert.Equal(t, svg.out, buf.String())
+	}
+
+	err := sanitizeSVG(&bytes.Buffer{}, strings.NewReader(`<svg>&xxe;</svg>`))
+	assert.ErrorIs(t, err, ErrInvalidSVG)
+}
+
+func TestAssetSanitize(t *testing.T) {
+	f, err := os.Open("../fixtures/logo.svg")
+	assert.NoError(t, err)
+	defer f.Close()
+
+	a, err := New(f)
+	assert.NoError(t, err)
+	defer util.CleanupTempFile(a.File)
+	assert.Equal(t, SVG, a.ContentType)
+	assert.Equal(t, IMAGE, a.Type)
+	sha := a.Sha256
+
+	assert.NoError(t, a.Sanitize())
+	assert.Equal(t, SVG, a.ContentType)
+	assert.NotEqual(t, sha, a.Sha256)
+
+	b, err := io.ReadAll(a.File)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(b)), a.Size)
+	for _, s := range []string{"script", "onload", "onclick", "javascript:", "example.com", "foreignObject"} {
+		assert.NotContains(t, string(b), s)
+	}
+	assert.Contains(t, string(b), `<use xlink:href="#gradient">`)
+}
+
+func TestAssetPaddedSVG(t *testing.T) {
+	// the svg element is beyond the bytes the content type is sniffed from
+	svg := `<?xml version="1.0"?>` + "\n<!--" + strings.Repeat(" ", 1024) + `-->` +
+		`<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`
+
+	a, err := New(strings.NewReader(svg))
+	assert.NoError(t, err)
+	defer util.CleanupTempFile(a.File)
+	assert.Equal(t, SVG, a.ContentType)
+	assert.Equal(t, IMAGE, a.Type)
+
+	assert.NoError(t, a.Sanitize())
+	b, err := io.ReadAll(a.File)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(b), "script")
+}
@@ -0,0 +1,228 @@
+package asset
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/davidbyttow/govips/v2/vips"
+	"golang.org/x/image/font/sfnt"
+)
+
+// DefaultFont is the font family text is drawn with when none is requested
+const DefaultFont = "sans"
+
+// Text is drawn over resized images
+type Text struct {
+	Text string
+	// Font is a font family, DefaultFont when empty
+	Font string
+	// Size is the height of the font in pixels
+	Size  int
+	Color vips.ColorRGBA
+	// Gravity, X and Y place the text like an Overlay, lines are aligned to the
+	// side it's placed against
+	Gravity Gravity
+	X       int
+	Y       int
+	// Width is the width lines are wrapped at, 0 or anything wider than the
+	// image wraps them at the image's width
+	Width int
+}
+
+// Fonts maps the names fonts are requested by, their file names without
+// extension, to the family and style they're drawn with
+type Fonts map[string]string
+
+// LoadFonts returns the TrueType and OpenType fonts in dir. Fontconfig is
+// pointed to a configuration including them, so it must be called before
+// libvips draws any text.
+func LoadFonts(dir string) (Fonts, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	fonts := Fonts{}
+	for _, file := range files {
+		ext := strings.ToLower(filepath.Ext(file.Name()))
+		if file.IsDir() || (ext != ".ttf" && ext != ".otf") {
+			continue
+		}
+
+		b, err := os.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		family, err := fontFamily(b)
+		if err != nil {
+			return nil, fmt.Errorf("font %s: %w", file.Name(), err)
+		}
+		fonts[strings.TrimSuffix(file.Name(), filepath.Ext(file.Name()))] = family
+	}
+
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	err = configureFontconfig(abs)
+	if err != nil {
+		return nil, err
+	}
+
+	return fonts, nil
+}
+
+// fontFamily returns the family and style of the font in b, such as "DejaVu Sans Bold",
+// which Pango understands as a font description
+func fontFamily(b []byte) (string, error) {
+	f, err := sfnt.Parse(b)
+	if err != nil {
+		return "", err
+	}
+
+	family, err := f.Name(nil, sfnt.NameIDTypographicFamily)
+	if err != nil {
+		family, err = f.Name(nil, sfnt.NameIDFamily)
+		if err != nil {
+			return "", err
+		}
+	}
+	style, err := f.Name(nil, sfnt.NameIDTypographicSubfamily)
+	if err != nil {
+		style, _ = f.Name(nil, sfnt.NameIDSubfamily)
+	}
+
+	if style == "" || style == "Regular" {
+		return family, nil
+	}
+	return family + " " + style, nil
+}
+
+// configureFontconfig writes a fontconfig configuration adding dir to the
+// system's fonts and has fontconfig use it
+func configureFontconfig(dir string) error {
+	system := os.Getenv("FONTCONFIG_FILE")
+	if system == "" {
+		system = "/etc/fonts/fonts.conf"
+	}
+
+	f, err := os.CreateTemp("", "air-fonts-*.conf")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, `<?xml version="1.0"?>
+<!DOCTYPE fontconfig SYSTEM "fonts.dtd">
+<fontconfig>
+  <dir>%s</dir>
+  <include ignore_missing="yes">%s</include>
+</fontconfig>
+`, attrEscaper.Replace(dir), attrEscaper.Replace(system))
+	if err != nil {
+		return err
+	}
+
+	return os.Setenv("FONTCONFIG_FILE", f.Name())
+}
+
+// position returns where the top left corner of text width by height pixels
+// is placed on an image imageWidth by imageHeight pixels
+func (t Text) position(imageWidth int, imageHeight int, width int, height int) (int, int) {
+	return position(t.Gravity, t.X, t.Y, imageWidth, imageHeight, width, height)
+}
+
+// drawText draws the text in rp over every frame of image
+func drawText(image *vips.ImageRef, rp *ResizeParams) error {
+	t := rp.Text
+	width := t.Width
+	if width <= 0 || width > image.Width() {
+		width = image.Width()
+	}
+
+	// the text is rendered white on black, then used as the alpha of its color
+	mask, err := vips.Black(width, image.PageHeight())
+	if err != nil {
+		return err
+	}
+	defer mask.Close()
+
+	font := t.Font
+	if font == "" {
+		font = DefaultFont
+	}
+	err = mask.Label(&vips.LabelParams{
+		// libvips renders text as Pango markup
+		Text:      textEscaper.Replace(t.Text),
+		Font:      fmt.Sprintf("%s %d", font, t.Size),
+		Width:     vips.Scalar{Value: float64(width)},
+		Opacity:   1,
+		Color:     vips.Color{R: 255, G: 255, B: 255},
+		Alignment: alignment(t.Gravity),
+	})
+	if err != nil {
+		return err
+	}
+
+	left, top, w, h, err := mask.FindTrim(0, &vips.Color{})
+	if err != nil {
+		return err
+	}
+	if w < 1 || h < 1 {
+		return nil
+	}
+	err = mask.ExtractArea(left, top, w, h)
+	if err != nil {
+		return err
+	}
+	err = mask.ExtractBand(0, 1)
+	if err != nil {
+		return err
+	}
+	if t.Color.A < 255 {
+		err = mask.Linear1(float64(t.Color.A)/255, 0)
+		if err != nil {
+			return err
+		}
+	}
+
+	text, err := vips.Black(w, h)
+	if err != nil {
+		return err
+	}
+	defer text.Close()
+
+	err = text.ToColorSpace(vips.InterpretationSRGB)
+	if err != nil {
+		return err
+	}
+	err = text.Linear([]float64{1, 1, 1}, []float64{float64(t.Color.R), float64(t.Color.G), float64(t.Color.B)})
+	if err != nil {
+		return err
+	}
+	err = text.BandJoin(mask)
+	if err != nil {
+		return err
+	}
+	err = text.Cast(vips.BandFormatUchar)
+	if err != nil {
+		return err
+	}
+
+	x, y := t.position(image.Width(), image.PageHeight(), w, h)
+	return compositeFrames(image, text, x, y)
+}
+
+// alignment returns how lines are aligned for text placed with g
+func alignment(g Gravity) vips.Align {
+	switch g {
+	case GravityNorthWest, GravityWest, GravitySouthWest:
+		return vips.AlignLow
+	case GravityNorthEast, GravityEast, GravitySouthEast:
+		return vips.AlignHigh
+	default:
+		return vips.AlignCenter
+	}
+}
@@ -1,6 +1,13 @@
 package asset
 
-import "github.com/davidbyttow/govips/v2/vips"
+import (
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/davidbyttow/govips/v2/vips"
+)
 
 var StringToInterestingTypes = map[string]vips.Interesting{
 	"none":      vips.InterestingNone,
@@ -22,12 +29,149 @@ var InterestingTypesToString = map[vips.Interesting]string{
 	vips.InterestingAll:       "all",
 }
 
-var ImageTypes = invertImageTypes()
+var StringToFitTypes = map[string]Fit{
+	"fill":    FitFill,
+	"cover":   FitCover,
+	"contain": FitContain,
+	"inside":  FitInside,
+	"outside": FitOutside,
+}
+
+var FitTypesToString = map[Fit]string{
+	FitFill:    "fill",
+	FitCover:   "cover",
+	FitContain: "contain",
+	FitInside:  "inside",
+	FitOutside: "outside",
+}
+
+var StringToFlipTypes = map[string]Flip{
+	"h": FlipHorizontal,
+	"v": FlipVertical,
+}
+
+var StringToGravityTypes = map[string]Gravity{
+	"centre":    GravityCentre,
+	"north":     GravityNorth,
+	"northeast": GravityNorthEast,
+	"east":      GravityEast,
+	"southeast": GravitySouthEast,
+	"south":     GravitySouth,
+	"southwest": GravitySouthWest,
+	"west":      GravityWest,
+	"northwest": GravityNorthWest,
+}
+
+// ParseColor parses hex colors such as ff0000, #f00 or transparent
+func ParseColor(s string) (vips.ColorRGBA, error) {
+	if s == "transparent" {
+		return vips.ColorRGBA{}, nil
+	}
+
+	s = strings.TrimPrefix(s, "#")
+	if len(s) == 3 {
+		s = string([]byte{s[0], s[0], s[1], s[1], s[2], s[2]})
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != 3 {
+		return vips.ColorRGBA{}, errors.New("color must be a hex color or transparent")
+	}
+
+	return vips.ColorRGBA{R: b[0], G: b[1], B: b[2], A: 255}, nil
+}
+
+// ParseRegion parses an area given as left,top,width,height where each value is
+// in pixels or in percent such as 25%
+func ParseRegion(s string) (Region, error) {
+	values := strings.Split(s, ",")
+	if len(values) != 4 {
+		return Region{}, errors.New("region must be left,top,width,height")
+	}
 
-func invertImageTypes() map[string]vips.ImageType {
-	m := map[string]vips.ImageType{}
-	for k, v := range vips.ImageTypes {
-		m[v] = k
+	var lengths [4]Length
+	for i, v := range values {
+		l, err := parseLength(strings.TrimSpace(v))
+		if err != nil {
+			return Region{}, err
+		}
+		lengths[i] = l
+	}
+	if lengths[2].Value == 0 || lengths[3].Value == 0 {
+		return Region{}, errors.New("region width and height must be above 0")
 	}
-	return m
+
+	return Region{Left: lengths[0], Top: lengths[1], Width: lengths[2], Height: lengths[3]}, nil
+}
+
+func parseLength(s string) (Length, error) {
+	if strings.HasSuffix(s, "%") {
+		f, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+		if err != nil || !(f >= 0 && f <= 100) {
+			return Length{}, errors.New("percentages must be between 0% and 100%")
+		}
+		return Length{Value: f, Percent: true}, nil
+	}
+
+	i, err := strconv.Atoi(s)
+	if err != nil || i < 0 {
+		return Length{}, errors.New("lengths must be positive numbers or percentages")
+	}
+	return Length{Value: float64(i)}, nil
+}
+
+// ParseFocalPoint parses a focal point given as x,y fractions between 0 and 1
+func ParseFocalPoint(s string) (FocalPoint, error) {
+	values := strings.Split(s, ",")
+	if len(values) != 2 {
+		return FocalPoint{}, errors.New("focal point must be x,y")
+	}
+
+	var coords [2]float64
+	for i, v := range values {
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return FocalPoint{}, errors.New("focal point coordinates must be numbers")
+		}
+		coords[i] = f
+	}
+
+	fp := FocalPoint{X: coords[0], Y: coords[1]}
+	return fp, fp.Validate()
+}
+
+// Validate checks that the coordinates of fp are between 0 and 1
+func (fp FocalPoint) Validate() error {
+	// written so NaN fails too
+	if !(fp.X >= 0 && fp.X <= 1 && fp.Y >= 0 && fp.Y <= 1) {
+		return errors.New("focal point coordinates must be between 0 and 1")
+	}
+	return nil
+}
+
+// ImageTypes are the formats images can be exported to
+var ImageTypes = map[string]vips.ImageType{
+	"avif": vips.ImageTypeAVIF,
+	"gif":  vips.ImageTypeGIF,
+	"jpeg": vips.ImageTypeJPEG,
+	"png":  vips.ImageTypePNG,
+	"tiff": vips.ImageTypeTIFF,
+	"webp": vips.ImageTypeWEBP,
+}
+
+// FallbackTypes maps the content types of images that aren't served in their own
+// format to the type used when no format is requested
+var FallbackTypes = map[string]vips.ImageType{
+	HEIC: DefaultImageType,
+	HEIF: DefaultImageType,
+	BMP:  vips.ImageTypePNG,
+}
+
+var StringToTiffCompression = map[string]vips.TiffCompression{
+	"none":     vips.TiffCompressionNone,
+	"jpeg":     vips.TiffCompressionJpeg,
+	"deflate":  vips.TiffCompressionDeflate,
+	"packbits": vips.TiffCompressionPackbits,
+	"lzw":      vips.TiffCompressionLzw,
+	"webp":     vips.TiffCompressionWebp,
+	"zstd":     vips.TiffCompressionZstd,
 }
@@ -0,0 +1,53 @@
+package cache
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Flight coalesces concurrent calls for the same key so the work is done once
+// and every caller receives the same result. The zero value is ready to use.
+type Flight struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+type call struct {
+	wg  sync.WaitGroup
+	val *Variant
+	err error
+}
+
+// Do calls fn unless a call for key is already in flight, in which case it waits
+// for it and returns its result. shared reports whether the result came from
+// another caller. A panic in fn is returned as an error to every caller.
+func (f *Flight) Do(key string, fn func() (*Variant, error)) (v *Variant, err error, shared bool) {
+	f.mu.Lock()
+	if f.calls == nil {
+		f.calls = map[string]*call{}
+	}
+	if c, ok := f.calls[key]; ok {
+		f.mu.Unlock()
+		c.wg.Wait()
+		return c.val, c.err, true
+	}
+
+	c := &call{}
+	c.wg.Add(1)
+	f.calls[key] = c
+	f.mu.Unlock()
+
+	defer func() {
+		if r := recover(); r != nil {
+			c.val, c.err = nil, fmt.Errorf("panic: %v", r)
+			v, err = c.val, c.err
+		}
+		f.mu.Lock()
+		delete(f.calls, key)
+		f.mu.Unlock()
+		c.wg.Done()
+	}()
+
+	c.val, c.err = fn()
+	return c.val, c.err, false
+}
@@ -0,0 +1,89 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlight(t *testing.T) {
+	var f Flight
+	var calls, shares int32
+	release := make(chan struct{})
+	v := &Variant{Data: []byte("data")}
+
+	fn := func() (*Variant, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return v, nil
+	}
+
+	var wg sync.WaitGroup
+	do := func() {
+		defer wg.Done()
+		res, err, shared := f.Do("a", fn)
+		assert.NoError(t, err)
+		assert.Equal(t, v, res)
+		if shared {
+			atomic.AddInt32(&shares, 1)
+		}
+	}
+
+	wg.Add(1)
+	go do()
+	for atomic.LoadInt32(&calls) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	for i := 0; i < 9; i++ {
+		wg.Add(1)
+		go do()
+	}
+	// give the other callers time to start waiting
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	assert.Equal(t, int32(9), atomic.LoadInt32(&shares))
+
+	errFailed := errors.New("failed")
+	_, err, shared := f.Do("a", func() (*Variant, error) { return nil, errFailed })
+	assert.Equal(t, errFailed, err)
+	assert.False(t, shared)
+}
+
+func TestFlightPanic(t *testing.T) {
+	var f Flight
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	done := make(chan error)
+	go func() {
+		_, err, _ := f.Do("a", func() (*Variant, error) {
+			close(started)
+			<-release
+			panic("boom")
+		})
+		done <- err
+	}()
+
+	<-started
+	go func() {
+		v, err, shared := f.Do("a", func() (*Variant, error) { return &Variant{}, nil })
+		assert.Nil(t, v)
+		assert.True(t, shared)
+		done <- err
+	}()
+	// give the second caller time to start waiting
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	for i := 0; i < 2; i++ {
+		assert.EqualError(t, <-done, "panic: boom")
+	}
+}
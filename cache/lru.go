@@ -0,0 +1,132 @@
+package cache
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+)
+
+type LRUOpts struct {
+	// MaxBytes is the total size in bytes of the entries the cache can hold
+	MaxBytes int64
+	// MaxEntrySize is the maximum size in bytes of a single entry, 0 means MaxBytes
+	MaxEntrySize int64
+}
+
+// LRUStats holds the counters of an LRU
+type LRUStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Entries   int
+	Bytes     int64
+}
+
+// LRU is an in-memory least recently used cache bounded by the total size of its entries
+type LRU struct {
+	*LRUOpts
+	mu        sync.Mutex
+	ll        *list.List
+	items     map[string]*list.Element
+	size      int64
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+type entry struct {
+	key   string
+	value []byte
+}
+
+func NewLRU(opts *LRUOpts) *LRU {
+	return &LRU{
+		LRUOpts: opts,
+		ll:      list.New(),
+		items:   map[string]*list.Element{},
+	}
+}
+
+func (c *LRU) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.ll.MoveToFront(el)
+		c.hits++
+		return el.Value.(*entry).value, true
+	}
+
+	c.misses++
+	return nil, false
+}
+
+// Add adds value to the cache unless it's larger than the maximum entry size.
+// The value must not be modified afterwards.
+func (c *LRU) Add(key string, value []byte) {
+	size := int64(len(value))
+	if !c.Fits(size) {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.ll.MoveToFront(el)
+		e := el.Value.(*entry)
+		c.size += size - int64(len(e.value))
+		e.value = value
+	} else {
+		c.items[key] = c.ll.PushFront(&entry{key, value})
+		c.size += size
+	}
+
+	for c.size > c.MaxBytes {
+		c.removeOldest()
+	}
+}
+
+// RemovePrefix removes all the entries whose key starts with prefix
+func (c *LRU) RemovePrefix(prefix string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, el := range c.items {
+		if strings.HasPrefix(key, prefix) {
+			c.removeElement(el)
+		}
+	}
+}
+
+// Fits reports whether a value of size bytes can be added to the cache
+func (c *LRU) Fits(size int64) bool {
+	return size <= c.MaxBytes && (c.MaxEntrySize <= 0 || size <= c.MaxEntrySize)
+}
+
+func (c *LRU) Stats() LRUStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return LRUStats{
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+		Entries:   c.ll.Len(),
+		Bytes:     c.size,
+	}
+}
+
+func (c *LRU) removeOldest() {
+	if el := c.ll.Back(); el != nil {
+		c.removeElement(el)
+		c.evictions++
+	}
+}
+
+func (c *LRU) removeElement(el *list.Element) {
+	c.ll.Remove(el)
+	e := el.Value.(*entry)
+	delete(c.items, e.key)
+	c.size -= int64(len(e.value))
+}
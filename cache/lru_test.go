@@ -0,0 +1,45 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLRU(t *testing.T) {
+	c := NewLRU(&LRUOpts{MaxBytes: 10, MaxEntrySize: 5})
+
+	_, ok := c.Get("a")
+	assert.False(t, ok)
+
+	c.Add("a", []byte("aaaa"))
+	c.Add("b", []byte("bbbb"))
+	c.Add("big", []byte("bigger"))
+
+	b, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("aaaa"), b)
+	_, ok = c.Get("big")
+	assert.False(t, ok)
+
+	// evicts b, the least recently used
+	c.Add("c", []byte("cccc"))
+	_, ok = c.Get("b")
+	assert.False(t, ok)
+	_, ok = c.Get("a")
+	assert.True(t, ok)
+	_, ok = c.Get("c")
+	assert.True(t, ok)
+
+	c.Add("c", []byte("cc"))
+	c.RemovePrefix("a")
+	assert.True(t, c.Fits(5))
+	assert.False(t, c.Fits(6))
+
+	s := c.Stats()
+	assert.Equal(t, uint64(3), s.Hits)
+	assert.Equal(t, uint64(3), s.Misses)
+	assert.Equal(t, uint64(1), s.Evictions)
+	assert.Equal(t, 1, s.Entries)
+	assert.Equal(t, int64(2), s.Bytes)
+}
@@ -0,0 +1,208 @@
+package cache
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"path"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/alexferl/air/asset"
+	"github.com/alexferl/air/storage"
+	"github.com/alexferl/air/util"
+)
+
+// ErrMiss is returned when a variant isn't cached
+var ErrMiss = errors.New("cache miss")
+
+// variantsPrefix keeps variants apart from the assets they're rendered from
+const variantsPrefix = "variants/"
+
+// Variant is a rendered image
+type Variant struct {
+	ContentType string
+	Data        []byte
+	Width       int
+	Height      int
+}
+
+// variantMagic starts encoded variants, it changes along with their layout
+const variantMagic = "airv1"
+
+// variantHeaderLen is the length of an encoded variant before its content type
+const variantHeaderLen = len(variantMagic) + 10
+
+// Encode returns v as it's cached, its dimensions and content type followed by its data
+func (v *Variant) Encode() []byte {
+	b := make([]byte, variantHeaderLen, variantHeaderLen+len(v.ContentType)+len(v.Data))
+	copy(b, variantMagic)
+	binary.BigEndian.PutUint32(b[len(variantMagic):], uint32(v.Width))
+	binary.BigEndian.PutUint32(b[len(variantMagic)+4:], uint32(v.Height))
+	binary.BigEndian.PutUint16(b[len(variantMagic)+8:], uint16(len(v.ContentType)))
+	b = append(b, v.ContentType...)
+	return append(b, v.Data...)
+}
+
+// DecodeVariant returns the variant encoded in b by Encode, its data shares b
+func DecodeVariant(b []byte) (*Variant, error) {
+	if len(b) < variantHeaderLen || string(b[:len(variantMagic)]) != variantMagic {
+		return nil, errInvalidVariant
+	}
+
+	n := variantHeaderLen + int(binary.BigEndian.Uint16(b[len(variantMagic)+8:]))
+	if len(b) < n {
+		return nil, errInvalidVariant
+	}
+
+	return &Variant{
+		ContentType: string(b[variantHeaderLen:n]),
+		Data:        b[n:],
+		Width:       int(binary.BigEndian.Uint32(b[len(variantMagic):])),
+		Height:      int(binary.BigEndian.Uint32(b[len(variantMagic)+4:])),
+	}, nil
+}
+
+var errInvalidVariant = errors.New("invalid variant")
+
+type VariantsOpts struct {
+	// MaxSize is the maximum size in bytes of a variant to cache, 0 means no limit
+	MaxSize int64
+	// TTL is how long a variant stays cached, 0 means forever
+	TTL time.Duration
+}
+
+// Variants caches rendered images in storage keyed by asset id and a
+// hash of the asset.ResizeParams used to render them. The total size of the
+// cache isn't bounded, expired variants are deleted when they're read again.
+type Variants struct {
+	*VariantsOpts
+	storage storage.Storage
+}
+
+func NewVariants(s storage.Storage, opts *VariantsOpts) *Variants {
+	return &Variants{
+		VariantsOpts: opts,
+		storage:      s,
+	}
+}
+
+func (v *Variants) Get(ctx context.Context, id string, key string) (*Variant, error) {
+	p, err := variantPath(id, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if v.TTL > 0 {
+		attrs, err := v.storage.Stat(ctx, p)
+		if err != nil {
+			if err == storage.ErrNotFound {
+				return nil, ErrMiss
+			}
+			return nil, err
+		}
+
+		if time.Since(attrs.LastModified) > v.TTL {
+			if err := v.storage.Delete(ctx, p); err != nil && err != storage.ErrNotFound {
+				log.Error().Msgf("Failed to delete expired variant %s: %v", p, err)
+			}
+			return nil, ErrMiss
+		}
+	}
+
+	r, err := v.storage.Get(ctx, p)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			return nil, ErrMiss
+		}
+		return nil, err
+	}
+	defer r.Close()
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		log.Error().Msgf("Failed to read variant %s: %v", p, err)
+		return nil, err
+	}
+
+	// variants cached in an older layout are rendered again
+	variant, err := DecodeVariant(b)
+	if err != nil {
+		log.Warn().Msgf("Failed to decode variant %s: %v", p, err)
+		return nil, ErrMiss
+	}
+
+	return variant, nil
+}
+
+func (v *Variants) Put(ctx context.Context, id string, key string, variant *Variant) error {
+	if v.MaxSize > 0 && int64(len(variant.Data)) > v.MaxSize {
+		return nil
+	}
+
+	p, err := variantPath(id, key)
+	if err != nil {
+		return err
+	}
+
+	f, err := util.CreateTempFile()
+	if err != nil {
+		return err
+	}
+	defer util.CleanupTempFile(f)
+
+	if _, err := f.Write(variant.Encode()); err != nil {
+		log.Error().Msgf("Failed to write variant to temp file: %v", err)
+		return err
+	}
+
+	// rewind file
+	f.Seek(0, 0)
+
+	a := &asset.Asset{
+		File:        f,
+		ContentType: "application/octet-stream",
+		Path:        p,
+		PathPrefix:  path.Dir(p),
+	}
+
+	return v.storage.Put(ctx, a)
+}
+
+// Purge deletes all the cached variants of an asset
+func (v *Variants) Purge(ctx context.Context, id string) error {
+	p, err := util.GetFullPathFromSha256(id)
+	if err != nil {
+		return err
+	}
+	prefix := variantsPrefix + p + "-"
+
+	cursor := ""
+	for {
+		list, err := v.storage.List(ctx, prefix, cursor, 100)
+		if err != nil {
+			return err
+		}
+
+		for _, o := range list.Objects {
+			if err := v.storage.Delete(ctx, o.Path); err != nil && err != storage.ErrNotFound {
+				return err
+			}
+		}
+
+		if list.NextCursor == "" {
+			return nil
+		}
+		cursor = list.NextCursor
+	}
+}
+
+func variantPath(id string, key string) (string, error) {
+	p, err := util.GetFullPathFromSha256(id)
+	if err != nil {
+		return "", err
+	}
+	return variantsPrefix + p + "-" + key, nil
+}
@@ -0,0 +1,79 @@
+package cache
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/alexferl/air/storage"
+)
+
+const testId = "b056dab52b1ad845a72da28ab28bcc39948011ec68122ff791da252afdfcd67e"
+
+func newTestVariants(t *testing.T, opts *VariantsOpts) *Variants {
+	dir, err := os.MkdirTemp("", "air-")
+	assert.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	fs, err := storage.NewFilesystem(&storage.FilesystemOpts{Path: dir})
+	assert.NoError(t, err)
+
+	return NewVariants(fs, opts)
+}
+
+func TestVariants(t *testing.T) {
+	ctx := context.Background()
+	v := newTestVariants(t, &VariantsOpts{MaxSize: 1024})
+	png, err := os.ReadFile("../fixtures/cat_640.png")
+	assert.NoError(t, err)
+
+	_, err = v.Get(ctx, testId, "a")
+	assert.Equal(t, ErrMiss, err)
+
+	assert.NoError(t, v.Put(ctx, testId, "a", &Variant{ContentType: "image/png", Data: []byte("data"), Width: 640,
+		Height: 426}))
+	assert.NoError(t, v.Put(ctx, testId, "b", &Variant{ContentType: "image/png", Data: png}))
+
+	variant, err := v.Get(ctx, testId, "a")
+	if assert.NoError(t, err) {
+		assert.Equal(t, []byte("data"), variant.Data)
+		assert.Equal(t, "image/png", variant.ContentType)
+		assert.Equal(t, 640, variant.Width)
+		assert.Equal(t, 426, variant.Height)
+	}
+
+	// larger than MaxSize
+	_, err = v.Get(ctx, testId, "b")
+	assert.Equal(t, ErrMiss, err)
+
+	assert.NoError(t, v.Purge(ctx, testId))
+	_, err = v.Get(ctx, testId, "a")
+	assert.Equal(t, ErrMiss, err)
+}
+
+func TestVariantsTTL(t *testing.T) {
+	ctx := context.Background()
+	v := newTestVariants(t, &VariantsOpts{TTL: time.Millisecond})
+
+	assert.NoError(t, v.Put(ctx, testId, "a", &Variant{ContentType: "text/plain", Data: []byte("data")}))
+	time.Sleep(10 * time.Millisecond)
+
+	_, err := v.Get(ctx, testId, "a")
+	assert.Equal(t, ErrMiss, err)
+}
+
+func TestDecodeVariant(t *testing.T) {
+	v := &Variant{ContentType: "image/webp", Data: []byte("data"), Width: 1280, Height: 851}
+	decoded, err := DecodeVariant(v.Encode())
+	if assert.NoError(t, err) {
+		assert.Equal(t, v, decoded)
+	}
+
+	for _, b := range []string{"", "data", variantMagic, variantMagic + "\x00\x00\x00\x01\x00\x00\x00\x01\x00\xffimage/png"} {
+		_, err = DecodeVariant([]byte(b))
+		assert.Equal(t, errInvalidVariant, err, b)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"runtime"
 	"time"
 
 	xconfig "github.com/alexferl/golib/config"
@@ -21,6 +22,12 @@ type Config struct {
 	TmpPath             string
 	Vips                *Vips
 	Storage             *Storage
+	VariantCache        *VariantCache
+	MemoryCache         *MemoryCache
+	Processing          *Processing
+	Resize              *Resize
+	Overlay             *Overlay
+	Text                *Text
 }
 
 // Vips holds vips specific configuration
@@ -31,6 +38,53 @@ type Vips struct {
 	MaxCacheFiles    int
 }
 
+// VariantCache holds rendered variants cache configuration
+type VariantCache struct {
+	Enabled     bool
+	StorageType string
+	MaxSize     int64
+	TTL         time.Duration
+}
+
+// MemoryCache holds in-memory cache configuration
+type MemoryCache struct {
+	Enabled      bool
+	MaxBytes     int64
+	MaxEntrySize int64
+}
+
+// Processing holds image processing pool configuration
+type Processing struct {
+	MaxConcurrency int
+	MaxQueue       int
+	MaxQueueWait   time.Duration
+}
+
+// Resize holds image resizing defaults
+type Resize struct {
+	Background string
+	// WithoutEnlargement keeps images from being made larger than their source by default
+	WithoutEnlargement bool
+	// DPRQuality lowers the quality of JPEG and WebP images requested for high density displays
+	DPRQuality bool
+}
+
+// Overlay holds image overlay configuration
+type Overlay struct {
+	// WatermarkPath is the image used with overlay=watermark, none if empty
+	WatermarkPath string
+	CacheMaxBytes int64
+}
+
+// Text holds text overlay configuration
+type Text struct {
+	Enabled bool
+	// FontsPath is a directory of TrueType and OpenType fonts text can be drawn with
+	FontsPath string
+	// MaxLength is the maximum number of characters of text
+	MaxLength int
+}
+
 type Storage struct {
 	Type       string
 	Filesystem *Filesystem
@@ -98,6 +152,36 @@ func NewConfig() *Config {
 				StorageClass: "STANDARD",
 			},
 		},
+		VariantCache: &VariantCache{
+			Enabled:     true,
+			StorageType: "",
+			MaxSize:     10 * 1024 * 1024, // 10MB
+			TTL:         30 * 24 * time.Hour,
+		},
+		MemoryCache: &MemoryCache{
+			Enabled:      true,
+			MaxBytes:     100 * 1024 * 1024, // 100MB
+			MaxEntrySize: 10 * 1024 * 1024,  // 10MB
+		},
+		Processing: &Processing{
+			MaxConcurrency: runtime.NumCPU(),
+			MaxQueue:       100,
+			MaxQueueWait:   time.Second * 10,
+		},
+		Resize: &Resize{
+			Background:         "ffffff",
+			WithoutEnlargement: false,
+			DPRQuality:         false,
+		},
+		Overlay: &Overlay{
+			WatermarkPath: "",
+			CacheMaxBytes: 20 * 1024 * 1024, // 20MB
+		},
+		Text: &Text{
+			Enabled:   false,
+			FontsPath: "",
+			MaxLength: 200,
+		},
 	}
 }
 
@@ -148,6 +232,53 @@ func (c *Config) addFlags(fs *pflag.FlagSet) {
 	fs.StringVar(&c.Storage.S3.Region, "s3-region", c.Storage.S3.Region, "AWS S3 region")
 	fs.StringVar(&c.Storage.S3.StorageClass, "s3-storage-class", c.Storage.S3.StorageClass,
 		"AWS S3 storage class")
+
+	// Variant cache
+	fs.BoolVar(&c.VariantCache.Enabled, "variant-cache-enabled", c.VariantCache.Enabled,
+		"Cache rendered variants in storage")
+	fs.StringVar(&c.VariantCache.StorageType, "variant-cache-storage-type", c.VariantCache.StorageType,
+		"Storage type to use for rendered variants, defaults to the assets storage type")
+	fs.Int64Var(&c.VariantCache.MaxSize, "variant-cache-max-size", c.VariantCache.MaxSize,
+		"Maximum size in bytes of a rendered variant to cache, 0 means no limit. The total size isn't bounded")
+	fs.DurationVar(&c.VariantCache.TTL, "variant-cache-ttl", c.VariantCache.TTL,
+		"How long rendered variants stay cached, 0 means forever")
+
+	// Memory cache
+	fs.BoolVar(&c.MemoryCache.Enabled, "memory-cache-enabled", c.MemoryCache.Enabled,
+		"Cache originals and rendered variants in memory")
+	fs.Int64Var(&c.MemoryCache.MaxBytes, "memory-cache-max-bytes", c.MemoryCache.MaxBytes,
+		"Maximum amount of memory in bytes the cache is allowed to use")
+	fs.Int64Var(&c.MemoryCache.MaxEntrySize, "memory-cache-max-entry-size", c.MemoryCache.MaxEntrySize,
+		"Maximum size in bytes of a single cached original or variant")
+
+	// Processing
+	fs.IntVar(&c.Processing.MaxConcurrency, "processing-max-concurrency", c.Processing.MaxConcurrency,
+		"Maximum amount of images processed concurrently, 0 means no limit")
+	fs.IntVar(&c.Processing.MaxQueue, "processing-max-queue", c.Processing.MaxQueue,
+		"Maximum amount of images waiting to be processed")
+	fs.DurationVar(&c.Processing.MaxQueueWait, "processing-max-queue-wait", c.Processing.MaxQueueWait,
+		"Maximum time an image waits to be processed, 0 means forever")
+
+	// Resize
+	fs.StringVar(&c.Resize.Background, "resize-background", c.Resize.Background,
+		"Background color of images padded to fit, a hex color or transparent")
+	fs.BoolVar(&c.Resize.WithoutEnlargement, "resize-without-enlargement", c.Resize.WithoutEnlargement,
+		"Don't make images larger than their source unless enlarge=true is requested")
+	fs.BoolVar(&c.Resize.DPRQuality, "resize-dpr-quality", c.Resize.DPRQuality,
+		"Lower the default quality of JPEG and WebP images requested with a dpr above 1")
+
+	// Overlay
+	fs.StringVar(&c.Overlay.WatermarkPath, "overlay-watermark-path", c.Overlay.WatermarkPath,
+		"Path of the image composited over images requested with overlay=watermark")
+	fs.Int64Var(&c.Overlay.CacheMaxBytes, "overlay-cache-max-bytes", c.Overlay.CacheMaxBytes,
+		"Maximum amount of memory in bytes used to cache overlay images, 0 disables the cache")
+
+	// Text
+	fs.BoolVar(&c.Text.Enabled, "text-enabled", c.Text.Enabled, "Allow drawing text over images")
+	fs.StringVar(&c.Text.FontsPath, "text-fonts-path", c.Text.FontsPath,
+		"Directory of TrueType and OpenType fonts text can be drawn with")
+	fs.IntVar(&c.Text.MaxLength, "text-max-length", c.Text.MaxLength,
+		"Maximum number of characters of text drawn over images")
 }
 
 func (c *Config) BindFlags() {
@@ -6,6 +6,10 @@ import (
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/viper"
 
+	"github.com/alexferl/air/asset"
+	"github.com/alexferl/air/cache"
+	"github.com/alexferl/air/handlers"
+	"github.com/alexferl/air/pool"
 	"github.com/alexferl/air/storage"
 )
 
@@ -43,3 +47,81 @@ func Storage(ctx context.Context, storageType string) (storage.Storage, error) {
 		return storage.NewFilesystem(fsConfig)
 	}
 }
+
+// Variants returns the rendered variants cache, or nil if it's disabled.
+// Variants are kept in s unless another storage type is configured.
+func Variants(ctx context.Context, s storage.Storage) (*cache.Variants, error) {
+	if !viper.GetBool("variant-cache-enabled") {
+		log.Info().Msg("Variant cache is disabled")
+		return nil, nil
+	}
+
+	if storageType := viper.GetString("variant-cache-storage-type"); storageType != "" {
+		var err error
+		s, err = Storage(ctx, storageType)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	opts := &cache.VariantsOpts{
+		MaxSize: viper.GetInt64("variant-cache-max-size"),
+		TTL:     viper.GetDuration("variant-cache-ttl"),
+	}
+	return cache.NewVariants(s, opts), nil
+}
+
+// Memory returns the in-memory cache, or nil if it's disabled
+func Memory() *cache.LRU {
+	if !viper.GetBool("memory-cache-enabled") {
+		log.Info().Msg("Memory cache is disabled")
+		return nil
+	}
+
+	return cache.NewLRU(&cache.LRUOpts{
+		MaxBytes:     viper.GetInt64("memory-cache-max-bytes"),
+		MaxEntrySize: viper.GetInt64("memory-cache-max-entry-size"),
+	})
+}
+
+// Pool returns the image processing pool
+func Pool() *pool.Pool {
+	return pool.New(&pool.Opts{
+		MaxConcurrency: viper.GetInt("processing-max-concurrency"),
+		MaxQueue:       viper.GetInt("processing-max-queue"),
+		MaxQueueWait:   viper.GetDuration("processing-max-queue-wait"),
+	})
+}
+
+// Overlays returns the overlay images cache, or nil if it's disabled
+func Overlays() *cache.LRU {
+	maxBytes := viper.GetInt64("overlay-cache-max-bytes")
+	if maxBytes <= 0 {
+		log.Info().Msg("Overlay cache is disabled")
+		return nil
+	}
+
+	return cache.NewLRU(&cache.LRUOpts{MaxBytes: maxBytes})
+}
+
+// Watermark returns the configured watermark, or nil if there's none
+func Watermark() (*handlers.Watermark, error) {
+	path := viper.GetString("overlay-watermark-path")
+	if path == "" {
+		return nil, nil
+	}
+
+	log.Info().Msgf("Using watermark '%s'", path)
+	return handlers.NewWatermark(path)
+}
+
+// Fonts returns the fonts text can be drawn with, or nil if there are none
+func Fonts() (asset.Fonts, error) {
+	path := viper.GetString("text-fonts-path")
+	if !viper.GetBool("text-enabled") || path == "" {
+		return nil, nil
+	}
+
+	log.Info().Msgf("Loading fonts from '%s'", path)
+	return asset.LoadFonts(path)
+}
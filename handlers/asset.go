@@ -6,17 +6,24 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"net/url"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 
+	"github.com/davidbyttow/govips/v2/vips"
 	"github.com/labstack/echo/v4"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/viper"
 
 	"github.com/alexferl/air/asset"
+	"github.com/alexferl/air/cache"
+	"github.com/alexferl/air/pool"
+	"github.com/alexferl/air/storage"
 	"github.com/alexferl/air/util"
 )
 
@@ -24,25 +31,49 @@ const (
 	maxWidth   = 5000
 	maxHeight  = 5000
 	maxQuality = 100
+	maxEffort  = 9
+	minDPR     = 1
+	maxDPR     = 4
+	maxAdjust  = 100
+	minGamma   = 0.1
+	maxGamma   = 10
+	maxBlur    = 100
+	maxSharpen = 10
+	// maxOverlayOffset keeps overlays near the images they're placed on
+	maxOverlayOffset = 5000
+	maxTextSize      = 500
+	// defaultTextSize is the font size in pixels of text when none is requested
+	defaultTextSize = 32
 )
 
+// watermarkOverlay is the overlay param for the server's watermark
+const watermarkOverlay = "watermark"
+
 func (h *Handler) Asset(c echo.Context) error {
 	id := c.Param("id")
-	format := c.QueryParam("format")
-	crop := c.QueryParam("crop")
+	req := c.Request()
 
-	if len(crop) > 0 {
-		if _, ok := asset.StringToInterestingTypes[crop]; !ok {
-			m := fmt.Sprintf("Unknown crop algorithm '%s'", crop)
-			return c.JSON(http.StatusBadRequest, ErrorResponse{m})
-		}
+	auto, err := parseAuto(c.QueryParam("auto"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{err.Error()})
 	}
 
-	width, height, quality, err := parseParams(c.QueryParams())
+	params := c.QueryParams()
+	if auto.size {
+		params = applyClientHints(params, req.Header)
+	}
+
+	rp, err := parseParams(params)
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, ErrorResponse{err.Error()})
 	}
 
+	if auto.format && rp.ImageType == vips.ImageTypeUnknown {
+		accept := req.Header.Get(echo.HeaderAccept)
+		rp.AcceptAVIF = accepts(accept, asset.AVIF)
+		rp.AcceptWebP = accepts(accept, asset.WEBP)
+	}
+
 	path, err := util.GetFullPathFromSha256(id)
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, ErrorResponse{"Invalid id"})
@@ -51,81 +82,400 @@ func (h *Handler) Asset(c echo.Context) error {
 	ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("file-upload-timeout"))
 	defer cancel()
 
-	f, err := h.Storage.Get(ctx, path)
+	if rp.Overlay.Id == watermarkOverlay {
+		if h.Watermark == nil {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{errNoWatermark.Error()})
+		}
+		rp.Overlay.Id = h.Watermark.Id
+	}
+
+	if rp.Text.Font != "" {
+		family, ok := h.Fonts[rp.Text.Font]
+		if !ok {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{fmt.Sprintf("Unknown text_font '%s'", rp.Text.Font)})
+		}
+		rp.Text.Font = family
+	}
+
+	// the focal point set for the asset is part of the params so changing it changes the ETag
+	if usesStoredFocalPoint(rp) {
+		fp, err := h.getFocalPoint(ctx, id)
+		if err != nil {
+			log.Error().Msgf("Failed to get focal point: %v", err)
+		} else if fp != nil {
+			rp.FocalPoint = *fp
+			rp.UseFocalPoint = true
+		}
+	}
+
+	// assets are content-addressed so the id and params fully identify the response
+	key := rp.Hash()
+	etag := fmt.Sprintf(`"%s-%s"`, id, key)
+
+	// caches need to know which request headers the response depends on
+	header := c.Response().Header()
+	if auto.format {
+		header.Add(echo.HeaderVary, echo.HeaderAccept)
+	}
+	if auto.size {
+		header.Add(echo.HeaderVary, clientHints)
+		header.Set("Accept-CH", clientHints)
+	}
+
+	// assets that were deleted or never existed aren't revalidated
+	attrs, err := h.Storage.Stat(ctx, path)
 	if err != nil {
-		return c.JSON(http.StatusNotFound, ErrorResponse{"File not found"})
+		if err == storage.ErrNotFound {
+			return c.JSON(http.StatusNotFound, ErrorResponse{"File not found"})
+		}
+		log.Error().Msgf("Failed to stat file: %v", err)
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{"Error sending file"})
+	}
+	header.Set("Cache-Control", "public, max-age=604800")
+	header.Set("ETag", etag)
+	header.Set(echo.HeaderLastModified, attrs.LastModified.UTC().Format(http.TimeFormat))
+
+	inm := req.Header.Get("If-None-Match")
+	if inm != "" && etagMatch(inm, etag) {
+		return c.NoContent(http.StatusNotModified)
+	}
+
+	// If-Modified-Since is ignored when If-None-Match is present
+	if inm == "" && notModifiedSince(req.Header.Get(echo.HeaderIfModifiedSince), attrs.LastModified) {
+		return c.NoContent(http.StatusNotModified)
+	}
+
+	// stores that don't know the content type get it sniffed from the first bytes
+	contentType := attrs.ContentType
+	if !isKnownContentType(contentType) {
+		contentType, err = h.sniffContentType(ctx, path, attrs.Size)
+		if err != nil {
+			if err == storage.ErrNotFound {
+				return c.JSON(http.StatusNotFound, ErrorResponse{"File not found"})
+			}
+			log.Error().Msgf("Failed to sniff content type: %v", err)
+			return c.JSON(http.StatusInternalServerError, ErrorResponse{"Error sending file"})
+		}
+	}
+
+	// non-image assets are sent as stored so ranges can be read from storage directly
+	if !isRendered(contentType, rp) {
+		return h.sendOriginal(ctx, c, path, contentType, attrs, etag)
+	}
+
+	if v, ok := h.getVariant(ctx, id, key); ok {
+		return sendVariant(c, v, etag, attrs.LastModified)
+	}
+
+	// HEAD requests never fetch or resize originals, images that weren't
+	// rendered yet are described by the stored asset
+	if req.Method == http.MethodHead {
+		return h.sendOriginal(ctx, c, path, contentType, attrs, etag)
+	}
+
+	// identical requests arriving together share a single fetch and resize
+	v, err, _ := h.flight.Do(variantKey(id, key), func() (*cache.Variant, error) {
+		return h.render(ctx, id, path, attrs.Size, rp, key)
+	})
+	if err != nil {
+		switch {
+		case err == errNotImage:
+			return h.sendOriginal(ctx, c, path, contentType, attrs, etag)
+		case err == storage.ErrNotFound:
+			return c.JSON(http.StatusNotFound, ErrorResponse{"File not found"})
+		case errors.Is(err, errUnknownFormat):
+			return c.JSON(http.StatusBadRequest, ErrorResponse{err.Error()})
+		case err == asset.ErrFrameOutOfRange:
+			return c.JSON(http.StatusBadRequest, ErrorResponse{"frame or page is above the number of pages"})
+		case err == errOverlayNotFound || err == errOverlayNotImage:
+			return c.JSON(http.StatusBadRequest, ErrorResponse{err.Error()})
+		case err == asset.ErrCropOutOfBounds:
+			return c.JSON(http.StatusBadRequest, ErrorResponse{"crop is outside of the image"})
+		case err == pool.ErrQueueFull || err == pool.ErrQueueTimeout:
+			header.Set("Cache-Control", "no-store")
+			header.Set("Retry-After", h.retryAfter())
+			return c.JSON(http.StatusServiceUnavailable, ErrorResponse{"Too many images being processed"})
+		default:
+			return c.JSON(http.StatusInternalServerError, ErrorResponse{"Error resizing image"})
+		}
+	}
+
+	return sendVariant(c, v, etag, attrs.LastModified)
+}
+
+var (
+	errNotImage      = errors.New("asset is not an image")
+	errUnknownFormat = errors.New("Unknown image format")
+)
+
+// retryAfter returns how many seconds clients should wait before retrying
+// when the processing queue is full
+func (h *Handler) retryAfter() string {
+	secs := int(math.Ceil(h.Pool.MaxQueueWait.Seconds()))
+	if secs < 1 {
+		secs = 1
+	}
+	return strconv.Itoa(secs)
+}
+
+// render resizes the asset at path and caches the result
+func (h *Handler) render(ctx context.Context, id string, path string, size int64, rp *asset.ResizeParams,
+	key string,
+) (*cache.Variant, error) {
+	f, err := h.getOriginal(ctx, id, path, size)
+	if err != nil {
+		return nil, err
 	}
 	defer f.Close()
 
 	a, err := asset.New(f)
 	if err != nil {
 		log.Error().Msgf("Failed to create asset: %v", err)
-		return c.JSON(http.StatusInternalServerError, ErrorResponse{"Error sending file"})
+		return nil, err
 	}
 	defer util.CleanupTempFile(a.File)
 
-	var reader io.Reader
-	if a.Type == "image" {
-		out, err := util.CreateTempFile()
-		if err != nil {
-			return c.JSON(http.StatusInternalServerError, ErrorResponse{"Error creating temporary file"})
-		}
-		defer util.CleanupTempFile(out)
-
-		rp := asset.NewResizeParams()
-		rp.Width = width
-		rp.Height = height
-		rp.Quality = quality
+	if !isRendered(a.ContentType, rp) {
+		return nil, errNotImage
+	}
 
-		if len(format) == 0 {
-			format = strings.Split(a.Ext, ".")[1]
+	if rp.ImageType == vips.ImageTypeUnknown {
+		format := strings.Split(a.Ext, ".")[1]
+		val, ok := autoImageType(rp, a.ContentType)
+		if !ok {
+			val, ok = asset.FallbackTypes[a.ContentType]
+		}
+		if !ok {
+			val, ok = asset.ImageTypes[format]
+		}
+		if !ok {
+			return nil, fmt.Errorf("%w '%s'", errUnknownFormat, format)
 		}
+		rp.ImageType = val
+	}
 
-		if val, ok := asset.ImageTypes[format]; ok {
-			rp.ImageType = val
-		} else {
-			m := fmt.Sprintf("Unknown image format '%s'", format)
-			return c.JSON(http.StatusBadRequest, ErrorResponse{m})
+	if rp.Overlay.Id != "" {
+		rp.OverlayImage, err = h.getOverlay(ctx, rp.Overlay.Id)
+		if err != nil {
+			return nil, err
 		}
+	}
 
-		b, err := a.Resize(rp)
+	if h.Pool != nil {
+		release, err := h.Pool.Acquire(ctx)
 		if err != nil {
-			return c.JSON(http.StatusInternalServerError, ErrorResponse{"Error resizing image"})
+			log.Warn().Msgf("Failed to acquire processing slot: %v", err)
+			return nil, err
 		}
-		reader = bytes.NewReader(b)
+		defer release()
+	}
+
+	b, err := a.Resize(rp)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &cache.Variant{ContentType: a.ContentType, Data: b, Width: a.Width, Height: a.Height}
+	h.putVariant(id, key, v)
+
+	return v, nil
+}
+
+// sniffContentType detects the content type of the asset at path from its first bytes
+func (h *Handler) sniffContentType(ctx context.Context, path string, size int64) (string, error) {
+	if size == 0 {
+		return asset.DetectContentType(nil), nil
+	}
+
+	length := int64(asset.SniffLen)
+	if size < length {
+		length = size
+	}
+	r, err := h.Storage.GetRange(ctx, path, 0, length)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+
+	return asset.DetectContentType(b), nil
+}
+
+// sendOriginal sends an asset as stored, reading ranges from storage directly
+func (h *Handler) sendOriginal(ctx context.Context, c echo.Context, path string, contentType string,
+	attrs *storage.Attrs, etag string,
+) error {
+	return streamRange(c, contentType, attrs.Size, etag, attrs.LastModified,
+		func(r *byteRange) (io.ReadCloser, error) {
+			if r == nil {
+				return h.Storage.Get(ctx, path)
+			}
+			return h.Storage.GetRange(ctx, path, r.start, r.length)
+		})
+}
+
+// sendVariant sends a rendered image along with its dimensions
+func sendVariant(c echo.Context, v *cache.Variant, etag string, lastModified time.Time) error {
+	if v.Width > 0 {
+		header := c.Response().Header()
+		header.Set("X-Image-Width", strconv.Itoa(v.Width))
+		header.Set("X-Image-Height", strconv.Itoa(v.Height))
+	}
+
+	return sendBytes(c, v.ContentType, v.Data, etag, lastModified)
+}
+
+// sendBytes sends rendered content honoring the request's Range headers
+func sendBytes(c echo.Context, contentType string, b []byte, etag string, lastModified time.Time) error {
+	return streamRange(c, contentType, int64(len(b)), etag, lastModified,
+		func(r *byteRange) (io.ReadCloser, error) {
+			if r == nil {
+				return io.NopCloser(bytes.NewReader(b)), nil
+			}
+			return io.NopCloser(bytes.NewReader(b[r.start : r.start+r.length])), nil
+		})
+}
+
+// dprQuality returns the quality images for displays with a device pixel ratio
+// of dpr can be compressed with, their extra pixels hide the artifacts.
+// 0 means the default quality.
+func dprQuality(dpr float64) int {
+	switch {
+	case dpr <= 1:
+		return 0
+	case dpr <= 2:
+		return 65
+	default:
+		return 50
+	}
+}
 
+// usesStoredFocalPoint reports whether images resized with rp are cropped
+// around the focal point set for their asset
+func usesStoredFocalPoint(rp *asset.ResizeParams) bool {
+	return rp.Fit == asset.FitCover && rp.Width > 0 && rp.Height > 0 && !rp.UseFocalPoint &&
+		rp.Interesting == asset.DefaultInterestingType
+}
+
+// isRendered reports whether assets of contentType go through Resize for rp,
+// SVGs and PDFs are sent as stored unless they're rasterized to another format
+func isRendered(contentType string, rp *asset.ResizeParams) bool {
+	if asset.IsVector(contentType) {
+		return rp.ImageType != vips.ImageTypeUnknown
 	}
+	return asset.IsImage(contentType)
+}
 
-	c.Response().Header().Set("Cache-Control", "public, max-age=604800")
-	return c.Stream(http.StatusOK, a.ContentType, reader)
+// isKnownContentType reports whether contentType is specific enough to be trusted
+// without sniffing the content, objects stored without one get a generic type
+func isKnownContentType(contentType string) bool {
+	switch contentType {
+	case "", "application/octet-stream", "binary/octet-stream":
+		return false
+	default:
+		return true
+	}
 }
 
-func parseParams(params url.Values) (int, int, int, error) {
+// parseParams returns the ResizeParams described by the query string. The
+// ImageType is left as vips.ImageTypeUnknown when no format is requested.
+func parseParams(params url.Values) (*asset.ResizeParams, error) {
 	w := params.Get("width")
 	h := params.Get("height")
 	s := params.Get("size")
 	q := params.Get("quality")
+	format := params.Get("format")
+	crop := params.Get("crop")
+	e := params.Get("effort")
+	l := params.Get("lossless")
+	fr := params.Get("frame")
+	pg := params.Get("page")
+	compression := params.Get("compression")
+	bd := params.Get("bitdepth")
+	fit := params.Get("fit")
+	bg := params.Get("background")
+	en := params.Get("enlarge")
+	d := params.Get("dpr")
+	fp := params.Get("fp")
+	rot := params.Get("rotate")
+	flip := params.Get("flip")
 
-	var width, height, quality int
+	var width, height, quality, effort, bitDepth int
+	frame := asset.AllFrames
+	var lossless bool
+	dpr := 1.0
 	var err error
 
 	if w != "" {
 		width, err = strconv.Atoi(w)
 		if err != nil {
-			return 0, 0, 0, errors.New("width must be a number")
+			return nil, errors.New("width must be a number")
 		}
 	}
 	if h != "" {
 		height, err = strconv.Atoi(h)
 		if err != nil {
-			return 0, 0, 0, errors.New("height must be a number")
+			return nil, errors.New("height must be a number")
 		}
 	}
 
 	if q != "" {
 		quality, err = strconv.Atoi(q)
 		if err != nil {
-			return 0, 0, 0, errors.New("quality must be a number")
+			return nil, errors.New("quality must be a number")
+		}
+	}
+
+	if e != "" {
+		effort, err = strconv.Atoi(e)
+		if err != nil {
+			return nil, errors.New("effort must be a number")
+		}
+	}
+
+	if l != "" {
+		lossless, err = strconv.ParseBool(l)
+		if err != nil {
+			return nil, errors.New("lossless must be a boolean")
+		}
+	}
+
+	if fr != "" {
+		frame, err = strconv.Atoi(fr)
+		if err != nil {
+			return nil, errors.New("frame must be a number")
+		}
+		if frame < 0 {
+			return nil, errors.New("frame cannot be less than 0")
+		}
+	}
+
+	if pg != "" {
+		if fr != "" {
+			return nil, errors.New("frame and page can't be used together")
+		}
+		page, err := strconv.Atoi(pg)
+		if err != nil {
+			return nil, errors.New("page must be a number")
+		}
+		if page < 1 {
+			return nil, errors.New("page cannot be less than 1")
+		}
+		// pages are numbered from 1 like in documents, frames from 0
+		frame = page - 1
+	}
+
+	if bd != "" {
+		bitDepth, err = strconv.Atoi(bd)
+		if err != nil {
+			return nil, errors.New("bitdepth must be a number")
+		}
+		if bitDepth != 8 && bitDepth != 16 {
+			return nil, errors.New("bitdepth must be 8 or 16")
 		}
 	}
 
@@ -139,31 +489,390 @@ func parseParams(params url.Values) (int, int, int, error) {
 			r := regexp.MustCompile(`(\d+)$`)
 			m := r.FindString(s)
 			if m == "" {
-				return 0, 0, 0, errors.New("incorrect format for size")
+				return nil, errors.New("incorrect format for size")
 			} else {
 				width, _ = strconv.Atoi(s)
 			}
 		}
 	}
 
+	if d != "" {
+		dpr, err = strconv.ParseFloat(d, 64)
+		if err != nil {
+			return nil, errors.New("dpr must be a number")
+		}
+		if dpr < minDPR || dpr > maxDPR {
+			return nil, errors.New(fmt.Sprintf("dpr must be between %d and %d", minDPR, maxDPR))
+		}
+		width = int(math.Round(float64(width) * dpr))
+		height = int(math.Round(float64(height) * dpr))
+	}
+
 	if width < 0 {
-		return 0, 0, 0, errors.New("width cannot be less than 0")
+		return nil, errors.New("width cannot be less than 0")
 	}
 	if height < 0 {
-		return 0, 0, 0, errors.New("height cannot be less than 0")
+		return nil, errors.New("height cannot be less than 0")
 	}
 	if quality < 0 {
-		return 0, 0, 0, errors.New("quality cannot be less than 0")
+		return nil, errors.New("quality cannot be less than 0")
 	}
 	if width > maxWidth {
-		return 0, 0, 0, errors.New(fmt.Sprintf("width cannot be above %d", maxWidth))
+		return nil, errors.New(fmt.Sprintf("width cannot be above %d", maxWidth))
 	}
 	if height > maxHeight {
-		return 0, 0, 0, errors.New(fmt.Sprintf("height cannot be above %d", maxHeight))
+		return nil, errors.New(fmt.Sprintf("height cannot be above %d", maxHeight))
 	}
 	if quality > maxQuality {
-		return 0, 0, 0, errors.New(fmt.Sprintf("quality cannot be above %d", maxQuality))
+		return nil, errors.New(fmt.Sprintf("quality cannot be above %d", maxQuality))
+	}
+	if effort < 0 {
+		return nil, errors.New("effort cannot be less than 0")
+	}
+	if effort > maxEffort {
+		return nil, errors.New(fmt.Sprintf("effort cannot be above %d", maxEffort))
+	}
+
+	rp := asset.NewResizeParams()
+	rp.Width = width
+	rp.Height = height
+	rp.Quality = quality
+	rp.Effort = effort
+	rp.Lossless = lossless
+	rp.Frame = frame
+	rp.BitDepth = bitDepth
+	rp.ImageType = vips.ImageTypeUnknown
+
+	if len(format) > 0 {
+		val, ok := asset.ImageTypes[format]
+		if !ok {
+			// govips can't encode JPEG XL yet, it's only served as uploaded
+			if format == "jxl" {
+				return nil, errors.New("Image format 'jxl' isn't supported yet")
+			}
+			return nil, errors.New(fmt.Sprintf("Unknown image format '%s'", format))
+		}
+		rp.ImageType = val
+	}
+
+	if strings.Contains(crop, ",") {
+		val, err := asset.ParseRegion(crop)
+		if err != nil {
+			return nil, errors.New("crop must be left,top,width,height in pixels or percentages")
+		}
+		rp.Crop = val
+	} else if len(crop) > 0 {
+		val, ok := asset.StringToInterestingTypes[crop]
+		if !ok {
+			return nil, errors.New(fmt.Sprintf("Unknown crop algorithm '%s'", crop))
+		}
+		rp.Interesting = val
+	}
+
+	if len(fp) > 0 {
+		if rp.Interesting != asset.DefaultInterestingType {
+			return nil, errors.New("crop algorithm and fp can't be used together")
+		}
+		val, err := asset.ParseFocalPoint(fp)
+		if err != nil {
+			return nil, errors.New("fp must be x,y between 0 and 1")
+		}
+		rp.FocalPoint = val
+		rp.UseFocalPoint = true
+	}
+
+	if len(fit) > 0 {
+		val, ok := asset.StringToFitTypes[fit]
+		if !ok {
+			return nil, errors.New(fmt.Sprintf("Unknown fit '%s'", fit))
+		}
+		rp.Fit = val
+	}
+
+	if bg == "" {
+		bg = viper.GetString("resize-background")
+	}
+	if bg != "" {
+		val, err := asset.ParseColor(bg)
+		if err != nil {
+			return nil, errors.New("background must be a hex color or transparent")
+		}
+		rp.Background = val
+	}
+
+	enlarge := !viper.GetBool("resize-without-enlargement")
+	if en != "" {
+		enlarge, err = strconv.ParseBool(en)
+		if err != nil {
+			return nil, errors.New("enlarge must be a boolean")
+		}
+	}
+	rp.WithoutEnlargement = !enlarge
+
+	if quality == 0 && viper.GetBool("resize-dpr-quality") {
+		rp.DPRQuality = dprQuality(dpr)
+	}
+
+	if rot != "" {
+		angle, err := strconv.ParseFloat(rot, 64)
+		if err != nil || math.IsNaN(angle) || math.IsInf(angle, 0) {
+			return nil, errors.New("rotate must be a number")
+		}
+		// the same angles give the same cache keys
+		rp.Rotate = math.Mod(math.Mod(angle, 360)+360, 360)
+	}
+
+	if len(flip) > 0 {
+		val, ok := asset.StringToFlipTypes[flip]
+		if !ok {
+			return nil, errors.New(fmt.Sprintf("Unknown flip '%s'", flip))
+		}
+		rp.Flip = val
+	}
+
+	if err := parseFilters(params, rp); err != nil {
+		return nil, err
+	}
+
+	if err := parseOverlay(params, rp); err != nil {
+		return nil, err
+	}
+
+	if err := parseText(params, rp); err != nil {
+		return nil, err
+	}
+
+	if len(compression) > 0 {
+		val, ok := asset.StringToTiffCompression[compression]
+		if !ok {
+			return nil, errors.New(fmt.Sprintf("Unknown compression '%s'", compression))
+		}
+		rp.Compression = val
+	}
+
+	return rp, nil
+}
+
+// parseFilters sets the filters of rp described by the query string
+func parseFilters(params url.Values, rp *asset.ResizeParams) error {
+	gs := params.Get("grayscale")
+	tint := params.Get("tint")
+
+	var err error
+
+	if gs != "" {
+		rp.Grayscale, err = strconv.ParseBool(gs)
+		if err != nil {
+			return errors.New("grayscale must be a boolean")
+		}
+	}
+
+	adjustments := []struct {
+		name  string
+		value string
+		dst   *int
+	}{
+		{"brightness", params.Get("brightness"), &rp.Brightness},
+		{"contrast", params.Get("contrast"), &rp.Contrast},
+		{"saturation", params.Get("saturation"), &rp.Saturation},
+	}
+	for _, a := range adjustments {
+		if a.value == "" {
+			continue
+		}
+		*a.dst, err = strconv.Atoi(a.value)
+		if err != nil {
+			return errors.New(fmt.Sprintf("%s must be a number", a.name))
+		}
+		if *a.dst < -maxAdjust || *a.dst > maxAdjust {
+			return errors.New(fmt.Sprintf("%s must be between -%d and %d", a.name, maxAdjust, maxAdjust))
+		}
+	}
+
+	amounts := []struct {
+		name  string
+		value string
+		min   float64
+		max   float64
+		dst   *float64
+	}{
+		{"gamma", params.Get("gamma"), minGamma, maxGamma, &rp.Gamma},
+		{"blur", params.Get("blur"), 0, maxBlur, &rp.Blur},
+		{"sharpen", params.Get("sharpen"), 0, maxSharpen, &rp.Sharpen},
+	}
+	for _, s := range amounts {
+		if s.value == "" {
+			continue
+		}
+		*s.dst, err = strconv.ParseFloat(s.value, 64)
+		if err != nil {
+			return errors.New(fmt.Sprintf("%s must be a number", s.name))
+		}
+		// written so NaN fails too
+		if !(*s.dst >= s.min && *s.dst <= s.max) {
+			return errors.New(fmt.Sprintf("%s must be between %g and %g", s.name, s.min, s.max))
+		}
+	}
+
+	if tint != "" {
+		rp.Tint, err = asset.ParseColor(tint)
+		if err != nil || rp.Tint.A == 0 {
+			return errors.New("tint must be a hex color")
+		}
+	}
+
+	return nil
+}
+
+// parseOverlay sets the overlay of rp described by the query string
+func parseOverlay(params url.Values, rp *asset.ResizeParams) error {
+	id := params.Get("overlay")
+	gravity := params.Get("overlay_gravity")
+	offset := params.Get("overlay_offset")
+	opacity := params.Get("overlay_opacity")
+	scale := params.Get("overlay_scale")
+
+	if id == "" {
+		if gravity != "" || offset != "" || opacity != "" || scale != "" {
+			return errors.New("overlay parameters require an overlay")
+		}
+		return nil
+	}
+
+	if id != watermarkOverlay {
+		if _, err := util.GetFullPathFromSha256(id); err != nil {
+			return errors.New("overlay must be an asset id or watermark")
+		}
+	}
+	rp.Overlay.Id = id
+
+	if gravity != "" {
+		val, ok := asset.StringToGravityTypes[gravity]
+		if !ok {
+			return errors.New(fmt.Sprintf("Unknown overlay_gravity '%s'", gravity))
+		}
+		rp.Overlay.Gravity = val
+	}
+
+	if offset != "" {
+		x, y, err := parseOffset("overlay_offset", offset)
+		if err != nil {
+			return err
+		}
+		rp.Overlay.X = x
+		rp.Overlay.Y = y
+	}
+
+	if opacity != "" {
+		val, err := strconv.ParseFloat(opacity, 64)
+		// written so NaN fails too
+		if err != nil || !(val >= 0 && val <= 1) {
+			return errors.New("overlay_opacity must be between 0 and 1")
+		}
+		rp.Overlay.Opacity = val
+	}
+
+	if scale != "" {
+		val, err := strconv.ParseFloat(scale, 64)
+		if err != nil || !(val > 0 && val <= 1) {
+			return errors.New("overlay_scale must be above 0 and at most 1")
+		}
+		rp.Overlay.Scale = val
+	}
+
+	return nil
+}
+
+// parseOffset returns the x,y offset given to the param name
+func parseOffset(name string, offset string) (int, int, error) {
+	xy := strings.Split(offset, ",")
+	if len(xy) != 2 {
+		return 0, 0, errors.New(fmt.Sprintf("%s must be x,y", name))
+	}
+	x, errX := strconv.Atoi(xy[0])
+	y, errY := strconv.Atoi(xy[1])
+	if errX != nil || errY != nil {
+		return 0, 0, errors.New(fmt.Sprintf("%s must be numbers", name))
+	}
+	if x < -maxOverlayOffset || x > maxOverlayOffset || y < -maxOverlayOffset || y > maxOverlayOffset {
+		return 0, 0, errors.New(fmt.Sprintf("%s must be between -%d and %d", name, maxOverlayOffset,
+			maxOverlayOffset))
+	}
+	return x, y, nil
+}
+
+// parseText sets the text of rp described by the query string. The font is
+// left as requested for the handler to look up.
+func parseText(params url.Values, rp *asset.ResizeParams) error {
+	text := params.Get("text")
+	font := params.Get("text_font")
+	size := params.Get("text_size")
+	color := params.Get("text_color")
+	gravity := params.Get("text_gravity")
+	offset := params.Get("text_offset")
+	width := params.Get("text_width")
+
+	if text == "" {
+		if font != "" || size != "" || color != "" || gravity != "" || offset != "" || width != "" {
+			return errors.New("text parameters require a text")
+		}
+		return nil
+	}
+
+	if !viper.GetBool("text-enabled") {
+		return errors.New("Text overlays are disabled")
+	}
+	if !utf8.ValidString(text) {
+		return errors.New("text must be UTF-8")
+	}
+	maxLength := viper.GetInt("text-max-length")
+	if utf8.RuneCountInString(text) > maxLength {
+		return errors.New(fmt.Sprintf("text must be at most %d characters", maxLength))
+	}
+	rp.Text = asset.Text{
+		Text:  text,
+		Font:  font,
+		Size:  defaultTextSize,
+		Color: vips.ColorRGBA{A: 255},
+	}
+
+	var err error
+
+	if size != "" {
+		rp.Text.Size, err = strconv.Atoi(size)
+		if err != nil || rp.Text.Size < 1 || rp.Text.Size > maxTextSize {
+			return errors.New(fmt.Sprintf("text_size must be between 1 and %d", maxTextSize))
+		}
+	}
+
+	if color != "" {
+		rp.Text.Color, err = asset.ParseColor(color)
+		if err != nil || rp.Text.Color.A == 0 {
+			return errors.New("text_color must be a hex color")
+		}
+	}
+
+	if gravity != "" {
+		val, ok := asset.StringToGravityTypes[gravity]
+		if !ok {
+			return errors.New(fmt.Sprintf("Unknown text_gravity '%s'", gravity))
+		}
+		rp.Text.Gravity = val
+	}
+
+	if offset != "" {
+		rp.Text.X, rp.Text.Y, err = parseOffset("text_offset", offset)
+		if err != nil {
+			return err
+		}
+	}
+
+	if width != "" {
+		rp.Text.Width, err = strconv.Atoi(width)
+		if err != nil || rp.Text.Width < 1 || rp.Text.Width > maxWidth {
+			return errors.New(fmt.Sprintf("text_width must be between 1 and %d", maxWidth))
+		}
 	}
 
-	return width, height, quality, nil
+	return nil
 }
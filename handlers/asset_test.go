@@ -1,24 +1,23 @@
 package handlers
 
 import (
-	"fmt"
 	"net/http"
-	"net/http/httptest"
+	"net/url"
+	"os"
 	"testing"
 
-	"github.com/labstack/echo/v4"
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestAssetBadRequests(t *testing.T) {
-	e := echo.New()
-	req := httptest.NewRequest(http.MethodGet, "/", nil)
-	rec := httptest.NewRecorder()
-	c := e.NewContext(req, rec)
-	c.SetPath("/:id")
-	c.SetParamNames("id")
-	c.SetParamValues("123")
-	h := &Handler{}
+	png, err := os.ReadFile("../fixtures/cat.png")
+	assert.NoError(t, err)
+	// a stored image so each request is rejected for the parameter it names
+	h, _ := newTestHandler(t, map[string][]byte{testId: png})
+
+	rec := serve(t, h.Asset, http.MethodGet, "123", "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
 
 	type params struct {
 		key   string
@@ -43,6 +42,61 @@ func TestAssetBadRequests(t *testing.T) {
 		{[]params{{"quality", "9001"}}, http.StatusBadRequest},
 		{[]params{{"format", "1"}}, http.StatusBadRequest},
 		{[]params{{"format", "a"}}, http.StatusBadRequest},
+		{[]params{{"effort", "-1"}}, http.StatusBadRequest},
+		{[]params{{"effort", "a"}}, http.StatusBadRequest},
+		{[]params{{"effort", "10"}}, http.StatusBadRequest},
+		{[]params{{"lossless", "a"}}, http.StatusBadRequest},
+		{[]params{{"frame", "-1"}}, http.StatusBadRequest},
+		{[]params{{"frame", "a"}}, http.StatusBadRequest},
+		{[]params{{"format", "bmp"}}, http.StatusBadRequest},
+		{[]params{{"format", "jxl"}}, http.StatusBadRequest},
+		{[]params{{"compression", "a"}}, http.StatusBadRequest},
+		{[]params{{"bitdepth", "a"}}, http.StatusBadRequest},
+		{[]params{{"bitdepth", "12"}}, http.StatusBadRequest},
+		{[]params{{"page", "0"}}, http.StatusBadRequest},
+		{[]params{{"page", "a"}}, http.StatusBadRequest},
+		{[]params{{"page", "1"}, {"frame", "0"}}, http.StatusBadRequest},
+		{[]params{{"fit", "a"}}, http.StatusBadRequest},
+		{[]params{{"background", "a"}}, http.StatusBadRequest},
+		{[]params{{"enlarge", "a"}}, http.StatusBadRequest},
+		{[]params{{"dpr", "a"}}, http.StatusBadRequest},
+		{[]params{{"dpr", "0.5"}}, http.StatusBadRequest},
+		{[]params{{"dpr", "5"}}, http.StatusBadRequest},
+		{[]params{{"width", "2000"}, {"dpr", "3"}}, http.StatusBadRequest},
+		{[]params{{"auto", "a"}}, http.StatusBadRequest},
+		{[]params{{"crop", "0,0,10"}}, http.StatusBadRequest},
+		{[]params{{"crop", "0,0,0,10"}}, http.StatusBadRequest},
+		{[]params{{"crop", "0,0,150%,10"}}, http.StatusBadRequest},
+		{[]params{{"fp", "a"}}, http.StatusBadRequest},
+		{[]params{{"rotate", "a"}}, http.StatusBadRequest},
+		{[]params{{"rotate", "NaN"}}, http.StatusBadRequest},
+		{[]params{{"flip", "a"}}, http.StatusBadRequest},
+		{[]params{{"grayscale", "a"}}, http.StatusBadRequest},
+		{[]params{{"brightness", "a"}}, http.StatusBadRequest},
+		{[]params{{"brightness", "101"}}, http.StatusBadRequest},
+		{[]params{{"contrast", "-101"}}, http.StatusBadRequest},
+		{[]params{{"saturation", "1.5"}}, http.StatusBadRequest},
+		{[]params{{"gamma", "0"}}, http.StatusBadRequest},
+		{[]params{{"gamma", "11"}}, http.StatusBadRequest},
+		{[]params{{"blur", "-1"}}, http.StatusBadRequest},
+		{[]params{{"blur", "NaN"}}, http.StatusBadRequest},
+		{[]params{{"sharpen", "11"}}, http.StatusBadRequest},
+		{[]params{{"tint", "transparent"}}, http.StatusBadRequest},
+		{[]params{{"tint", "a"}}, http.StatusBadRequest},
+		{[]params{{"overlay", "a"}}, http.StatusBadRequest},
+		{[]params{{"overlay", "watermark"}}, http.StatusBadRequest},
+		{[]params{{"overlay_gravity", "north"}}, http.StatusBadRequest},
+		{[]params{{"overlay", "watermark"}, {"overlay_gravity", "a"}}, http.StatusBadRequest},
+		{[]params{{"overlay", "watermark"}, {"overlay_offset", "1"}}, http.StatusBadRequest},
+		{[]params{{"overlay", "watermark"}, {"overlay_offset", "a,1"}}, http.StatusBadRequest},
+		{[]params{{"overlay", "watermark"}, {"overlay_offset", "9000,0"}}, http.StatusBadRequest},
+		{[]params{{"overlay", "watermark"}, {"overlay_opacity", "2"}}, http.StatusBadRequest},
+		{[]params{{"overlay", "watermark"}, {"overlay_scale", "0"}}, http.StatusBadRequest},
+		{[]params{{"text", "air"}}, http.StatusBadRequest},
+		{[]params{{"text_size", "16"}}, http.StatusBadRequest},
+		{[]params{{"fp", "1.5,0.5"}}, http.StatusBadRequest},
+		{[]params{{"fp", "0.5,0.5"}, {"crop", "attention"}}, http.StatusBadRequest},
+		{[]params{{"auto", "format,a"}}, http.StatusBadRequest},
 		{[]params{{"width", "100"}, {"height", "-1"}}, http.StatusBadRequest},
 		{[]params{{"width", "-1"}, {"height", "100"}}, http.StatusBadRequest},
 		{[]params{{"width", "100"}, {"height", "a"}}, http.StatusBadRequest},
@@ -53,15 +107,132 @@ func TestAssetBadRequests(t *testing.T) {
 	}
 
 	for _, request := range requests {
-		req.URL.RawQuery = ""
+		q := url.Values{}
 		for _, param := range request.params {
-			q := req.URL.Query()
 			q.Add(param.key, param.value)
-			req.URL.RawQuery = q.Encode()
 		}
-		fmt.Printf("Query params: %s\n", req.URL.RawQuery)
-		if assert.NoError(t, h.Asset(c)) {
-			assert.Equal(t, request.code, rec.Code)
+		rec := serve(t, h.Asset, http.MethodGet, testId, q.Encode())
+		assert.Equal(t, request.code, rec.Code, q.Encode())
+	}
+}
+
+func TestAssetConditional(t *testing.T) {
+	h, _ := newTestHandler(t, map[string][]byte{testId: []byte("data")})
+
+	rec := serve(t, h.Asset, http.MethodGet, testId, "width=100")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	etag := rec.Header().Get("ETag")
+	lastModified := rec.Header().Get("Last-Modified")
+	assert.NotEmpty(t, etag)
+	assert.NotEmpty(t, lastModified)
+	assert.NotEqual(t, etag, serve(t, h.Asset, http.MethodGet, testId, "width=200").Header().Get("ETag"))
+
+	requests := []struct {
+		query  string
+		header string
+		value  string
+		code   int
+	}{
+		{"width=100", "If-None-Match", etag, http.StatusNotModified},
+		{"width=100", "If-None-Match", "W/" + etag, http.StatusNotModified},
+		{"width=100", "If-None-Match", `"other", ` + etag, http.StatusNotModified},
+		{"width=100", "If-None-Match", "*", http.StatusNotModified},
+		{"width=200", "If-None-Match", etag, http.StatusOK},
+		{"width=100", "If-Modified-Since", lastModified, http.StatusNotModified},
+		{"width=100", "If-Modified-Since", "Mon, 01 Jan 2001 00:00:00 GMT", http.StatusOK},
+		{"width=100", "If-Modified-Since", "invalid", http.StatusOK},
+	}
+
+	for _, request := range requests {
+		rec := serve(t, h.Asset, http.MethodGet, testId, request.query, request.header, request.value)
+		assert.Equal(t, request.code, rec.Code, "%s: %s", request.header, request.value)
+		if request.code == http.StatusNotModified {
+			assert.Equal(t, 0, rec.Body.Len())
+		}
+	}
+
+	// deleted assets aren't revalidated
+	rec = serve(t, h.DeleteAsset, http.MethodDelete, testId, "")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	for _, header := range [][]string{
+		{"If-None-Match", etag},
+		{"If-None-Match", "*"},
+		{"If-Modified-Since", lastModified},
+	} {
+		rec = serve(t, h.Asset, http.MethodGet, testId, "width=100", header...)
+		assert.Equal(t, http.StatusNotFound, rec.Code, header[0])
+	}
+}
+
+func TestAssetDimensions(t *testing.T) {
+	b, err := os.ReadFile("../fixtures/cat.png")
+	assert.NoError(t, err)
+	h, _ := newTestHandler(t, map[string][]byte{testId: b})
+
+	requests := []struct {
+		query  string
+		width  string
+		height string
+	}{
+		{"width=640", "640", "426"},
+		{"width=4000&enlarge=false", "1280", "851"},
+	}
+
+	for _, request := range requests {
+		rec := serve(t, h.Asset, http.MethodGet, testId, request.query)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, request.width, rec.Header().Get("X-Image-Width"), request.query)
+		assert.Equal(t, request.height, rec.Header().Get("X-Image-Height"), request.query)
+	}
+}
+
+func TestAssetViewportWidth(t *testing.T) {
+	b, err := os.ReadFile("../fixtures/cat.png")
+	assert.NoError(t, err)
+	h, _ := newTestHandler(t, map[string][]byte{testId: b})
+
+	requests := []struct {
+		viewport string
+		width    string
+	}{
+		{"640", "640"},
+		// the viewport is an upper bound, smaller images keep their size
+		{"2000", "1280"},
+	}
+
+	for _, request := range requests {
+		rec := serve(t, h.Asset, http.MethodGet, testId, "auto=size", "Viewport-Width", request.viewport)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, request.width, rec.Header().Get("X-Image-Width"), request.viewport)
+	}
+}
+
+func TestParseParamsDPR(t *testing.T) {
+	viper.Set("resize-dpr-quality", true)
+	defer viper.Set("resize-dpr-quality", nil)
+
+	requests := []struct {
+		query   string
+		width   int
+		height  int
+		quality int
+	}{
+		{"width=100&height=50", 100, 50, 0},
+		{"width=100&height=50&dpr=1", 100, 50, 0},
+		{"width=100&height=50&dpr=2", 200, 100, 65},
+		{"size=100x50&dpr=1.5", 150, 75, 65},
+		{"width=101&dpr=2.5", 253, 0, 50},
+		{"width=100&dpr=3&quality=90", 300, 0, 0},
+	}
+
+	for _, request := range requests {
+		q, _ := url.ParseQuery(request.query)
+		rp, err := parseParams(q)
+		if assert.NoError(t, err, request.query) {
+			assert.Equal(t, request.width, rp.Width, request.query)
+			assert.Equal(t, request.height, rp.Height, request.query)
+			assert.Equal(t, request.quality, rp.DPRQuality, request.query)
 		}
 	}
 }
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"io"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+
+	"github.com/alexferl/air/cache"
+)
+
+// memory cache keys start with the asset id so they can be removed together
+func variantKey(id string, key string) string {
+	return id + "-" + key
+}
+
+// getOriginal returns the stored asset at path, from memory when it was recently fetched
+func (h *Handler) getOriginal(ctx context.Context, id string, path string, size int64) (io.ReadCloser, error) {
+	if h.Memory == nil || !h.Memory.Fits(size) {
+		return h.Storage.Get(ctx, path)
+	}
+
+	if b, ok := h.Memory.Get(id); ok {
+		return io.NopCloser(bytes.NewReader(b)), nil
+	}
+
+	f, err := h.Storage.Get(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	b, err := io.ReadAll(f)
+	if err != nil {
+		log.Error().Msgf("Failed to read file: %v", err)
+		return nil, err
+	}
+	h.Memory.Add(id, b)
+
+	return io.NopCloser(bytes.NewReader(b)), nil
+}
+
+// getVariant returns a rendered image from memory or from the variant cache
+func (h *Handler) getVariant(ctx context.Context, id string, key string) (*cache.Variant, bool) {
+	if h.Memory != nil {
+		if b, ok := h.Memory.Get(variantKey(id, key)); ok {
+			v, err := cache.DecodeVariant(b)
+			if err == nil {
+				return v, true
+			}
+			log.Error().Msgf("Failed to decode variant from memory: %v", err)
+		}
+	}
+
+	if h.Variants != nil {
+		v, err := h.Variants.Get(ctx, id, key)
+		if err == nil {
+			if h.Memory != nil {
+				h.Memory.Add(variantKey(id, key), v.Encode())
+			}
+			return v, true
+		}
+		if err != cache.ErrMiss {
+			log.Error().Msgf("Failed to get variant from cache: %v", err)
+		}
+	}
+
+	return nil, false
+}
+
+// putVariant caches a rendered image in memory and in the background in the variant cache
+func (h *Handler) putVariant(id string, key string, v *cache.Variant) {
+	if h.Memory != nil {
+		h.Memory.Add(variantKey(id, key), v.Encode())
+	}
+
+	if h.Variants != nil {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("file-upload-timeout"))
+			defer cancel()
+
+			if err := h.Variants.Put(ctx, id, key, v); err != nil {
+				log.Error().Msgf("Failed to put variant in cache: %v", err)
+			}
+		}()
+	}
+}
+
+// purge removes an asset, its rendered images and its use as an overlay from the caches
+func (h *Handler) purge(ctx context.Context, id string) {
+	if h.Memory != nil {
+		h.Memory.RemovePrefix(id)
+	}
+
+	if h.Overlays != nil {
+		h.Overlays.RemovePrefix(id)
+	}
+
+	if h.Variants != nil {
+		if err := h.Variants.Purge(ctx, id); err != nil {
+			log.Error().Msgf("Failed to purge variants of %s: %v", id, err)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// etagMatch reports whether etag matches any of the entity tags in an
+// If-None-Match header value, using the weak comparison function
+func etagMatch(header string, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// notModifiedSince reports whether lastModified isn't after the time
+// in an If-Modified-Since header value
+func notModifiedSince(header string, lastModified time.Time) bool {
+	if header == "" || lastModified.IsZero() {
+		return false
+	}
+
+	t, err := http.ParseTime(header)
+	if err != nil {
+		return false
+	}
+
+	// HTTP dates have a one second resolution
+	return !lastModified.Truncate(time.Second).After(t)
+}
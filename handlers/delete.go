@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+
+	"github.com/alexferl/air/storage"
+	"github.com/alexferl/air/util"
+)
+
+// DeleteAsset removes an asset from storage
+func (h *Handler) DeleteAsset(c echo.Context) error {
+	id := c.Param("id")
+
+	path, err := util.GetFullPathFromSha256(id)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{"Invalid id"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("file-upload-timeout"))
+	defer cancel()
+
+	err = h.Storage.Delete(ctx, path)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			return c.JSON(http.StatusNotFound, ErrorResponse{"File not found"})
+		}
+		log.Error().Msgf("Failed to delete file: %v", err)
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{"Error deleting file"})
+	}
+
+	if err := h.putFocalPoint(ctx, id, nil); err != nil {
+		log.Error().Msgf("Failed to delete focal point of %s: %v", id, err)
+	}
+
+	h.purge(ctx, id)
+
+	return c.NoContent(http.StatusNoContent)
+}
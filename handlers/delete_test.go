@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/alexferl/air/util"
+)
+
+func TestDeleteAsset(t *testing.T) {
+	h, dir := newTestHandler(t, map[string][]byte{testId: []byte("data")})
+
+	requests := []struct {
+		id   string
+		code int
+	}{
+		{"123", http.StatusBadRequest},
+		{testId, http.StatusNoContent},
+		{testId, http.StatusNotFound},
+	}
+
+	for _, request := range requests {
+		rec := serve(t, h.DeleteAsset, http.MethodDelete, request.id, "")
+		assert.Equal(t, request.code, rec.Code)
+	}
+
+	path, _ := util.GetFullPathFromSha256(testId)
+	_, err := os.Stat(filepath.Join(dir, path))
+	assert.True(t, os.IsNotExist(err))
+}
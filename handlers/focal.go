@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"path"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/alexferl/air/asset"
+	"github.com/alexferl/air/storage"
+	"github.com/alexferl/air/util"
+)
+
+// focalPointsPrefix keeps the focal points set for assets apart from the assets
+const focalPointsPrefix = "focalpoints/"
+
+func focalPointPath(id string) (string, error) {
+	p, err := util.GetFullPathFromSha256(id)
+	if err != nil {
+		return "", err
+	}
+	return focalPointsPrefix + p + ".json", nil
+}
+
+// getFocalPoint returns the focal point set for an asset, nil if there's none.
+// It isn't kept in memory as other instances couldn't tell when it changes.
+func (h *Handler) getFocalPoint(ctx context.Context, id string) (*asset.FocalPoint, error) {
+	p, err := focalPointPath(id)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := h.Storage.Get(ctx, p)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer r.Close()
+
+	var fp asset.FocalPoint
+	if err := json.NewDecoder(r).Decode(&fp); err != nil {
+		log.Error().Msgf("Failed to decode focal point %s: %v", p, err)
+		return nil, err
+	}
+
+	return &fp, nil
+}
+
+// putFocalPoint sets the focal point of an asset, nil removes it
+func (h *Handler) putFocalPoint(ctx context.Context, id string, fp *asset.FocalPoint) error {
+	p, err := focalPointPath(id)
+	if err != nil {
+		return err
+	}
+
+	// storage doesn't always replace existing objects
+	if err := h.Storage.Delete(ctx, p); err != nil && err != storage.ErrNotFound {
+		return err
+	}
+	if fp == nil {
+		return nil
+	}
+
+	f, err := util.CreateTempFile()
+	if err != nil {
+		return err
+	}
+	defer util.CleanupTempFile(f)
+
+	if err := json.NewEncoder(f).Encode(fp); err != nil {
+		log.Error().Msgf("Failed to write focal point to temp file: %v", err)
+		return err
+	}
+
+	// rewind file
+	f.Seek(0, io.SeekStart)
+
+	a := &asset.Asset{
+		File:        f,
+		ContentType: "application/json",
+		Path:        p,
+		PathPrefix:  path.Dir(p),
+	}
+
+	return h.Storage.Put(ctx, a)
+}
@@ -1,11 +1,30 @@
 package handlers
 
-import "github.com/alexferl/air/storage"
+import (
+	"github.com/alexferl/air/asset"
+	"github.com/alexferl/air/cache"
+	"github.com/alexferl/air/pool"
+	"github.com/alexferl/air/storage"
+)
 
 type (
 	// Handler represents the structure of our resource
 	Handler struct {
 		Storage storage.Storage
+		// Variants caches rendered images, nil if disabled
+		Variants *cache.Variants
+		// Memory caches originals and rendered images, nil if disabled
+		Memory *cache.LRU
+		// Pool bounds concurrent image processing, nil means no limit
+		Pool *pool.Pool
+		// Overlays caches the images of overlays by asset id, nil if disabled
+		Overlays *cache.LRU
+		// Watermark is composited over images requested with overlay=watermark, nil if none
+		Watermark *Watermark
+		// Fonts are the fonts text can be drawn with besides the default one
+		Fonts asset.Fonts
+
+		flight cache.Flight
 	}
 )
 
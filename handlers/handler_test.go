@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/alexferl/air/storage"
+	"github.com/alexferl/air/util"
+)
+
+const testId = "b056dab52b1ad845a72da28ab28bcc39948011ec68122ff791da252afdfcd67e"
+
+// newTestHandler returns a Handler backed by filesystem storage in a
+// temporary directory containing the given files keyed by sha256
+func newTestHandler(t *testing.T, files map[string][]byte) (*Handler, string) {
+	dir, err := os.MkdirTemp("", "air-")
+	assert.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	for id, b := range files {
+		path, err := util.GetFullPathFromSha256(id)
+		assert.NoError(t, err)
+		fullPath := filepath.Join(dir, path)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(fullPath), os.ModePerm))
+		assert.NoError(t, os.WriteFile(fullPath, b, 0o644))
+	}
+
+	fs, err := storage.NewFilesystem(&storage.FilesystemOpts{Path: dir})
+	assert.NoError(t, err)
+
+	return &Handler{Storage: fs}, dir
+}
+
+// serve calls handler for the asset id with the query string and the headers
+// given as names followed by values, as the router would
+func serve(t *testing.T, handler echo.HandlerFunc, method string, id string, query string,
+	headers ...string,
+) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/?"+query, nil)
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	c.SetPath("/:id")
+	c.SetParamNames("id")
+	c.SetParamValues(id)
+	assert.NoError(t, handler(c))
+	return rec
+}
@@ -0,0 +1,12 @@
+package handlers
+
+import (
+	"github.com/labstack/echo/v4"
+)
+
+// HeadAsset returns the headers of an asset without its body. They're those of
+// a GET with the same parameters when its image is cached, or else the size
+// and content type of the stored asset.
+func (h *Handler) HeadAsset(c echo.Context) error {
+	return h.Asset(c)
+}
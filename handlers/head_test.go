@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeadAsset(t *testing.T) {
+	b, err := os.ReadFile("../fixtures/cat.png")
+	assert.NoError(t, err)
+	h, _ := newTestHandler(t, map[string][]byte{testId: b})
+
+	// images that weren't rendered yet are described by the stored asset
+	rec := serve(t, h.HeadAsset, http.MethodHead, testId, "width=640")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, 0, rec.Body.Len())
+	assert.Equal(t, strconv.Itoa(len(b)), rec.Header().Get(echo.HeaderContentLength))
+	assert.Empty(t, rec.Header().Get("X-Image-Width"))
+	key := strings.TrimSuffix(strings.TrimPrefix(rec.Header().Get("ETag"), `"`+testId+"-"), `"`)
+	_, ok := h.getVariant(context.Background(), testId, key)
+	assert.False(t, ok, "HEAD shouldn't render")
+
+	for _, query := range []string{"", "width=640", "width=640&format=webp"} {
+		get := serve(t, h.Asset, http.MethodGet, testId, query)
+		head := serve(t, h.HeadAsset, http.MethodHead, testId, query)
+		assert.Equal(t, http.StatusOK, head.Code, query)
+		assert.Equal(t, 0, head.Body.Len(), query)
+		for _, k := range []string{
+			echo.HeaderContentType, echo.HeaderContentLength, echo.HeaderLastModified, "ETag",
+			"Cache-Control", "Accept-Ranges", "X-Image-Width", "X-Image-Height",
+		} {
+			assert.Equal(t, get.Header().Get(k), head.Header().Get(k), query+" "+k)
+		}
+	}
+
+	rec = serve(t, h.HeadAsset, http.MethodHead, testId, "")
+	assert.Equal(t, "image/png", rec.Header().Get(echo.HeaderContentType))
+	assert.NotEmpty(t, rec.Header().Get("ETag"))
+
+	rec = serve(t, h.HeadAsset, http.MethodHead, "0000000000000000000000000000000000000000000000000000000000000000", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
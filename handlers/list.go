@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+
+	"github.com/alexferl/air/storage"
+	"github.com/alexferl/air/util"
+)
+
+const (
+	defaultListLimit = 100
+	maxListLimit     = 1000
+)
+
+type AssetList struct {
+	Assets     []AssetInfo `json:"assets"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+}
+
+type AssetInfo struct {
+	Id           string    `json:"id"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+// ListAssets returns a page of stored assets
+func (h *Handler) ListAssets(c echo.Context) error {
+	cursor, limit, err := parseListParams(c.QueryParams())
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{err.Error()})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("file-download-timeout"))
+	defer cancel()
+
+	objects, next, err := h.listAssets(ctx, cursor, limit)
+	if err != nil {
+		log.Error().Msgf("Failed to list files: %v", err)
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{"Error listing files"})
+	}
+
+	resp := AssetList{Assets: []AssetInfo{}}
+	for _, o := range objects {
+		// skip anything that wasn't stored by an upload
+		id := path.Base(o.Path)
+		if p, err := util.GetFullPathFromSha256(id); err != nil || p != o.Path {
+			continue
+		}
+		resp.Assets = append(resp.Assets, AssetInfo{
+			Id:           id,
+			Size:         o.Size,
+			LastModified: o.LastModified.UTC(),
+		})
+	}
+
+	if next != "" {
+		resp.NextCursor = base64.RawURLEncoding.EncodeToString([]byte(next))
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// listAssets returns up to limit objects after cursor and the cursor of the next
+// page. The directories assets are sharded into sort before the variants and
+// focal points stored next to them, so listing stops at the first path outside.
+func (h *Handler) listAssets(ctx context.Context, cursor string, limit int) ([]*storage.Object, string, error) {
+	// one more object tells whether there's a next page
+	list, err := h.Storage.List(ctx, "", cursor, limit+1)
+	if err != nil {
+		return nil, "", err
+	}
+
+	for i, o := range list.Objects {
+		if !isSharded(o.Path) {
+			return list.Objects[:i], "", nil
+		}
+	}
+
+	if len(list.Objects) > limit {
+		return list.Objects[:limit], list.Objects[limit-1].Path, nil
+	}
+	return list.Objects, "", nil
+}
+
+// isSharded returns whether p is in one of the top level directories assets are
+// sharded into
+func isSharded(p string) bool {
+	if len(p) < 3 || p[2] != '/' {
+		return false
+	}
+	_, err := strconv.ParseUint(p[:2], 16, 8)
+	return err == nil && strings.ToLower(p[:2]) == p[:2]
+}
+
+func parseListParams(params url.Values) (string, int, error) {
+	cur := params.Get("cursor")
+	l := params.Get("limit")
+
+	var cursor string
+	limit := defaultListLimit
+
+	if cur != "" {
+		b, err := base64.RawURLEncoding.DecodeString(cur)
+		if err != nil {
+			return "", 0, errors.New("invalid cursor")
+		}
+		cursor = string(b)
+	}
+
+	if l != "" {
+		var err error
+		limit, err = strconv.Atoi(l)
+		if err != nil {
+			return "", 0, errors.New("limit must be a number")
+		}
+	}
+
+	if limit < 1 {
+		return "", 0, errors.New("limit cannot be less than 1")
+	}
+	if limit > maxListLimit {
+		return "", 0, fmt.Errorf("limit cannot be above %d", maxListLimit)
+	}
+
+	return cursor, limit, nil
+}
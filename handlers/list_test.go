@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/alexferl/air/util"
+)
+
+func TestListAssets(t *testing.T) {
+	ids := []string{
+		strings.Repeat("a", 64),
+		strings.Repeat("b", 64),
+		strings.Repeat("c", 64),
+	}
+	files := map[string][]byte{}
+	for _, id := range ids {
+		files[id] = []byte(id)
+	}
+	h, dir := newTestHandler(t, files)
+
+	// variants and focal points are stored next to assets but never listed
+	variant, err := util.GetFullPathFromSha256(ids[0])
+	assert.NoError(t, err)
+	focalPoint, err := util.GetFullPathFromSha256(ids[2])
+	assert.NoError(t, err)
+	for _, p := range []string{"variants/" + variant + "-0123456789abcdef", "focalpoints/" + focalPoint + ".json"} {
+		assert.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, p)), os.ModePerm))
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, p), []byte("{}"), 0o644))
+	}
+
+	e := echo.New()
+	list := func(query string) (int, AssetList) {
+		req := httptest.NewRequest(http.MethodGet, "/?"+query, nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		var resp AssetList
+		if assert.NoError(t, h.ListAssets(c)) && rec.Code == http.StatusOK {
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		}
+		return rec.Code, resp
+	}
+
+	code, resp := list("limit=2")
+	assert.Equal(t, http.StatusOK, code)
+	if assert.Len(t, resp.Assets, 2) {
+		assert.Equal(t, ids[0], resp.Assets[0].Id)
+		assert.Equal(t, ids[1], resp.Assets[1].Id)
+		assert.Equal(t, int64(64), resp.Assets[0].Size)
+	}
+	assert.NotEmpty(t, resp.NextCursor)
+
+	code, resp = list("limit=2&cursor=" + resp.NextCursor)
+	assert.Equal(t, http.StatusOK, code)
+	if assert.Len(t, resp.Assets, 1) {
+		assert.Equal(t, ids[2], resp.Assets[0].Id)
+	}
+	assert.Empty(t, resp.NextCursor)
+
+	// a page filled by the last assets has no next one
+	code, resp = list("limit=3")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, resp.Assets, 3)
+	assert.Empty(t, resp.NextCursor)
+
+	for _, query := range []string{"limit=0", "limit=a", "limit=9001", "cursor=!"} {
+		code, _ = list(query)
+		assert.Equal(t, http.StatusBadRequest, code)
+	}
+}
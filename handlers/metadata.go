@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+
+	"github.com/alexferl/air/asset"
+	"github.com/alexferl/air/storage"
+	"github.com/alexferl/air/util"
+)
+
+type AssetMetadata struct {
+	Id           string    `json:"id"`
+	ContentType  string    `json:"content_type"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+	Width        int       `json:"width,omitempty"`
+	Height       int       `json:"height,omitempty"`
+	// Pages is the number of pages of documents or frames of animated images
+	Pages int `json:"pages,omitempty"`
+	// FocalPoint is where the subject of an image is, used when cropping with fit=cover
+	FocalPoint *asset.FocalPoint `json:"focal_point,omitempty"`
+}
+
+// MetadataUpdate holds the metadata that can be set on an asset, the focal
+// point is removed when focal_point is null or missing
+type MetadataUpdate struct {
+	FocalPoint *asset.FocalPoint `json:"focal_point"`
+}
+
+// Metadata returns what's known about an asset, including the dimensions and
+// page count of images and documents
+func (h *Handler) Metadata(c echo.Context) error {
+	id := c.Param("id")
+
+	path, err := util.GetFullPathFromSha256(id)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{"Invalid id"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("file-download-timeout"))
+	defer cancel()
+
+	attrs, err := h.Storage.Stat(ctx, path)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			return c.JSON(http.StatusNotFound, ErrorResponse{"File not found"})
+		}
+		log.Error().Msgf("Failed to stat file: %v", err)
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{"Error reading file"})
+	}
+
+	f, err := h.getOriginal(ctx, id, path, attrs.Size)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			return c.JSON(http.StatusNotFound, ErrorResponse{"File not found"})
+		}
+		log.Error().Msgf("Failed to get file: %v", err)
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{"Error reading file"})
+	}
+	defer f.Close()
+
+	a, err := asset.New(f)
+	if err != nil {
+		log.Error().Msgf("Failed to create asset: %v", err)
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{"Error reading file"})
+	}
+	defer util.CleanupTempFile(a.File)
+
+	resp := AssetMetadata{
+		Id:           id,
+		ContentType:  a.ContentType,
+		Size:         attrs.Size,
+		LastModified: attrs.LastModified,
+	}
+
+	if a.Type == asset.IMAGE || asset.IsVector(a.ContentType) {
+		if h.Pool != nil {
+			release, err := h.Pool.Acquire(ctx)
+			if err != nil {
+				c.Response().Header().Set("Retry-After", h.retryAfter())
+				return c.JSON(http.StatusServiceUnavailable, ErrorResponse{"Too many images being processed"})
+			}
+			defer release()
+		}
+
+		m, err := a.Metadata()
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, ErrorResponse{"Error reading image"})
+		}
+		resp.Width = m.Width
+		resp.Height = m.Height
+		resp.Pages = m.Pages
+
+		resp.FocalPoint, err = h.getFocalPoint(ctx, id)
+		if err != nil {
+			log.Error().Msgf("Failed to get focal point: %v", err)
+			return c.JSON(http.StatusInternalServerError, ErrorResponse{"Error reading file"})
+		}
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// UpdateMetadata sets the metadata of an asset that isn't read from its content
+func (h *Handler) UpdateMetadata(c echo.Context) error {
+	id := c.Param("id")
+
+	path, err := util.GetFullPathFromSha256(id)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{"Invalid id"})
+	}
+
+	var update MetadataUpdate
+	d := json.NewDecoder(c.Request().Body)
+	d.DisallowUnknownFields()
+	if err := d.Decode(&update); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{"Invalid metadata"})
+	}
+	if update.FocalPoint != nil {
+		if err := update.FocalPoint.Validate(); err != nil {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{err.Error()})
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("file-upload-timeout"))
+	defer cancel()
+
+	_, err = h.Storage.Stat(ctx, path)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			return c.JSON(http.StatusNotFound, ErrorResponse{"File not found"})
+		}
+		log.Error().Msgf("Failed to stat file: %v", err)
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{"Error updating metadata"})
+	}
+
+	err = h.putFocalPoint(ctx, id, update.FocalPoint)
+	if err != nil {
+		log.Error().Msgf("Failed to put focal point: %v", err)
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{"Error updating metadata"})
+	}
+
+	return h.Metadata(c)
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/alexferl/air/asset"
+)
+
+func TestMetadata(t *testing.T) {
+	png, err := os.ReadFile("../fixtures/cat.png")
+	assert.NoError(t, err)
+	pdf, err := os.ReadFile("../fixtures/document.pdf")
+	assert.NoError(t, err)
+
+	pdfId := "2222222222222222222222222222222222222222222222222222222222222222"
+	dataId := "1111111111111111111111111111111111111111111111111111111111111111"
+	h, _ := newTestHandler(t, map[string][]byte{testId: png, pdfId: pdf, dataId: []byte("data")})
+
+	get := func(id string) (*httptest.ResponseRecorder, AssetMetadata) {
+		rec := serve(t, h.Metadata, http.MethodGet, id, "")
+
+		var m AssetMetadata
+		if rec.Code == http.StatusOK {
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &m))
+		}
+		return rec, m
+	}
+
+	rec, m := get(testId)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, AssetMetadata{
+		Id:           testId,
+		ContentType:  "image/png",
+		Size:         int64(len(png)),
+		LastModified: m.LastModified,
+		Width:        1280,
+		Height:       851,
+		Pages:        1,
+	}, m)
+
+	rec, m = get(pdfId)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/pdf", m.ContentType)
+	assert.Equal(t, 200, m.Width)
+	assert.Equal(t, 100, m.Height)
+	assert.Equal(t, 2, m.Pages)
+
+	rec, m = get(dataId)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, 0, m.Pages)
+
+	rec, _ = get("0000000000000000000000000000000000000000000000000000000000000000")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec, _ = get("123")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestUpdateMetadata(t *testing.T) {
+	png, err := os.ReadFile("../fixtures/cat.png")
+	assert.NoError(t, err)
+
+	h, _ := newTestHandler(t, map[string][]byte{testId: png})
+	e := echo.New()
+
+	patch := func(id string, body string) (*httptest.ResponseRecorder, AssetMetadata) {
+		req := httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPath("/:id/metadata")
+		c.SetParamNames("id")
+		c.SetParamValues(id)
+		assert.NoError(t, h.UpdateMetadata(c))
+
+		var m AssetMetadata
+		if rec.Code == http.StatusOK {
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &m))
+		}
+		return rec, m
+	}
+
+	rec, m := patch(testId, `{"focal_point": {"x": 0.3, "y": 0.6}}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, &asset.FocalPoint{X: 0.3, Y: 0.6}, m.FocalPoint)
+
+	// replacing it
+	rec, m = patch(testId, `{"focal_point": {"x": 1, "y": 0}}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, &asset.FocalPoint{X: 1, Y: 0}, m.FocalPoint)
+
+	rec, m = patch(testId, `{"focal_point": null}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Nil(t, m.FocalPoint)
+
+	rec, _ = patch(testId, `{"focal_point": {"x": 2, "y": 0}}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec, _ = patch(testId, `{"width": 100}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec, _ = patch(testId, `a`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec, _ = patch("0000000000000000000000000000000000000000000000000000000000000000", `{}`)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
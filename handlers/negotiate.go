@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/davidbyttow/govips/v2/vips"
+
+	"github.com/alexferl/air/asset"
+)
+
+// clientHints are the hints used to size images with auto=size
+const clientHints = "Sec-CH-DPR, Sec-CH-Width, Viewport-Width"
+
+// autoParams are what's chosen for clients with the auto parameter
+type autoParams struct {
+	format bool
+	size   bool
+}
+
+// parseAuto parses a comma separated list of format and size
+func parseAuto(s string) (*autoParams, error) {
+	auto := &autoParams{}
+	if s == "" {
+		return auto, nil
+	}
+
+	for _, v := range strings.Split(s, ",") {
+		switch strings.TrimSpace(v) {
+		case "format":
+			auto.format = true
+		case "size":
+			auto.size = true
+		default:
+			return nil, errors.New(fmt.Sprintf("Unknown auto '%s'", v))
+		}
+	}
+	return auto, nil
+}
+
+// applyClientHints returns a copy of params with the dpr and width the client
+// hints in header describe, explicit params win and invalid hints are ignored
+func applyClientHints(params url.Values, header http.Header) url.Values {
+	p := url.Values{}
+	for k, v := range params {
+		p[k] = v
+	}
+
+	dpr := 1.0
+	if d, ok := parseHint(header.Get("Sec-CH-DPR")); ok && p.Get("dpr") == "" {
+		dpr = math.Max(minDPR, math.Min(d, maxDPR))
+		p.Set("dpr", strconv.FormatFloat(dpr, 'f', -1, 64))
+	}
+
+	if p.Get("width") != "" || p.Get("height") != "" || p.Get("size") != "" {
+		return p
+	}
+
+	// Sec-CH-Width is already in device pixels
+	if width, ok := parseHint(header.Get("Sec-CH-Width")); ok {
+		p.Set("width", strconv.Itoa(int(math.Min(math.Ceil(width), maxWidth))))
+		p.Del("dpr")
+		return p
+	}
+
+	// images are at most as wide as the viewport, smaller ones aren't enlarged to fill it
+	if width, ok := parseHint(header.Get("Viewport-Width")); ok {
+		p.Set("width", strconv.Itoa(int(math.Min(math.Ceil(width), math.Floor(maxWidth/dpr)))))
+		if p.Get("enlarge") == "" {
+			p.Set("enlarge", "false")
+		}
+	}
+
+	return p
+}
+
+// parseHint parses a positive number client hint
+func parseHint(s string) (float64, bool) {
+	if s == "" {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || f <= 0 || math.IsInf(f, 0) {
+		return 0, false
+	}
+	return f, true
+}
+
+// accepts reports whether an Accept header value explicitly lists contentType
+// with a quality above 0, wildcards don't say anything about newer formats
+func accepts(header string, contentType string) bool {
+	for _, r := range strings.Split(header, ",") {
+		parts := strings.Split(r, ";")
+		if !strings.EqualFold(strings.TrimSpace(parts[0]), contentType) {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range parts[1:] {
+			kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(kv) == 2 && kv[0] == "q" {
+				var err error
+				q, err = strconv.ParseFloat(kv[1], 64)
+				if err != nil {
+					q = 0
+				}
+			}
+		}
+		return q > 0
+	}
+	return false
+}
+
+// autoImageType returns the best format the client accepts for an image of
+// contentType, animated formats are only converted to WebP so they keep their frames
+func autoImageType(rp *asset.ResizeParams, contentType string) (vips.ImageType, bool) {
+	animated := contentType == asset.GIF || contentType == asset.WEBP
+	switch {
+	case animated && rp.AcceptWebP:
+		return vips.ImageTypeWEBP, true
+	case animated:
+		return vips.ImageTypeUnknown, false
+	case rp.AcceptAVIF:
+		return vips.ImageTypeAVIF, true
+	case rp.AcceptWebP:
+		return vips.ImageTypeWEBP, true
+	default:
+		return vips.ImageTypeUnknown, false
+	}
+}
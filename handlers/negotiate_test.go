@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/davidbyttow/govips/v2/vips"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/alexferl/air/asset"
+)
+
+func TestAccepts(t *testing.T) {
+	chrome := "image/avif,image/webp,image/apng,image/svg+xml,image/*,*/*;q=0.8"
+
+	assert.True(t, accepts(chrome, asset.AVIF))
+	assert.True(t, accepts(chrome, asset.WEBP))
+	assert.True(t, accepts("image/jpeg, image/webp;q=0.5", asset.WEBP))
+	assert.False(t, accepts("image/webp;q=0", asset.WEBP))
+	assert.False(t, accepts("image/*,*/*", asset.AVIF))
+	assert.False(t, accepts("", asset.WEBP))
+}
+
+func TestAutoImageType(t *testing.T) {
+	rp := asset.NewResizeParams()
+	rp.AcceptAVIF = true
+	rp.AcceptWebP = true
+
+	val, ok := autoImageType(rp, asset.JPEG)
+	assert.True(t, ok)
+	assert.Equal(t, vips.ImageTypeAVIF, val)
+
+	val, ok = autoImageType(rp, asset.GIF)
+	assert.True(t, ok)
+	assert.Equal(t, vips.ImageTypeWEBP, val)
+
+	rp.AcceptWebP = false
+	_, ok = autoImageType(rp, asset.GIF)
+	assert.False(t, ok)
+
+	rp.AcceptAVIF = false
+	_, ok = autoImageType(rp, asset.PNG)
+	assert.False(t, ok)
+}
+
+func TestApplyClientHints(t *testing.T) {
+	hints := func(kv ...string) http.Header {
+		h := http.Header{}
+		for i := 0; i < len(kv); i += 2 {
+			h.Set(kv[i], kv[i+1])
+		}
+		return h
+	}
+
+	p := applyClientHints(url.Values{}, hints("Sec-CH-Width", "640", "Sec-CH-DPR", "2"))
+	assert.Equal(t, "640", p.Get("width"))
+	assert.Equal(t, "", p.Get("dpr"))
+
+	p = applyClientHints(url.Values{}, hints("Viewport-Width", "400", "Sec-CH-DPR", "2"))
+	assert.Equal(t, "400", p.Get("width"))
+	assert.Equal(t, "2", p.Get("dpr"))
+	assert.Equal(t, "false", p.Get("enlarge"))
+
+	p = applyClientHints(url.Values{"enlarge": {"true"}}, hints("Viewport-Width", "400"))
+	assert.Equal(t, "400", p.Get("width"))
+	assert.Equal(t, "true", p.Get("enlarge"))
+
+	p = applyClientHints(url.Values{}, hints("Viewport-Width", "9000", "Sec-CH-DPR", "8"))
+	assert.Equal(t, "1250", p.Get("width"))
+	assert.Equal(t, "4", p.Get("dpr"))
+
+	p = applyClientHints(url.Values{"width": {"100"}, "dpr": {"1"}}, hints("Sec-CH-Width", "640", "Sec-CH-DPR", "2"))
+	assert.Equal(t, "100", p.Get("width"))
+	assert.Equal(t, "1", p.Get("dpr"))
+
+	p = applyClientHints(url.Values{}, hints("Sec-CH-Width", "a", "Sec-CH-DPR", "-1"))
+	assert.Equal(t, "", p.Get("width"))
+	assert.Equal(t, "", p.Get("dpr"))
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/minio/sha256-simd"
+	"github.com/rs/zerolog/log"
+
+	"github.com/alexferl/air/asset"
+	"github.com/alexferl/air/storage"
+	"github.com/alexferl/air/util"
+)
+
+var (
+	errNoWatermark     = errors.New("No watermark is configured")
+	errOverlayNotFound = errors.New("Overlay not found")
+	errOverlayNotImage = errors.New("Overlay is not an image")
+)
+
+// Watermark is the server's image composited over images requested with overlay=watermark
+type Watermark struct {
+	Data []byte
+	// Id identifies the image in cache keys so they change along with it
+	Id string
+}
+
+// NewWatermark reads the watermark image at path
+func NewWatermark(path string) (*Watermark, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !asset.IsImage(asset.DetectContentType(b)) {
+		return nil, fmt.Errorf("watermark %s is not an image", path)
+	}
+
+	h := sha256.Sum256(b)
+	return &Watermark{Data: b, Id: fmt.Sprintf("%s-%x", watermarkOverlay, h[:8])}, nil
+}
+
+// getOverlay returns the image of the overlay id, from memory when it was
+// already used
+func (h *Handler) getOverlay(ctx context.Context, id string) ([]byte, error) {
+	if h.Watermark != nil && id == h.Watermark.Id {
+		return h.Watermark.Data, nil
+	}
+
+	if h.Overlays != nil {
+		if b, ok := h.Overlays.Get(id); ok {
+			return b, nil
+		}
+	}
+
+	path, err := util.GetFullPathFromSha256(id)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := h.Storage.Get(ctx, path)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			return nil, errOverlayNotFound
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	b, err := io.ReadAll(f)
+	if err != nil {
+		log.Error().Msgf("Failed to read overlay: %v", err)
+		return nil, err
+	}
+
+	if !asset.IsImage(asset.DetectContentType(b)) {
+		return nil, errOverlayNotImage
+	}
+
+	if h.Overlays != nil {
+		h.Overlays.Add(id, b)
+	}
+
+	return b, nil
+}
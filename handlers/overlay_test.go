@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/alexferl/air/cache"
+)
+
+func TestOverlay(t *testing.T) {
+	png, err := os.ReadFile("../fixtures/cat.png")
+	assert.NoError(t, err)
+	logo, err := os.ReadFile("../fixtures/cat_640.png")
+	assert.NoError(t, err)
+
+	logoId := "3333333333333333333333333333333333333333333333333333333333333333"
+	dataId := "1111111111111111111111111111111111111111111111111111111111111111"
+	missingId := "0000000000000000000000000000000000000000000000000000000000000000"
+	h, _ := newTestHandler(t, map[string][]byte{testId: png, logoId: logo, dataId: []byte("data")})
+	h.Overlays = cache.NewLRU(&cache.LRUOpts{MaxBytes: 10 * 1024 * 1024})
+	h.Watermark, err = NewWatermark("../fixtures/cat_640.png")
+	assert.NoError(t, err)
+
+	rec := serve(t, h.Asset, http.MethodGet, testId,
+		"width=200&overlay="+logoId+"&overlay_scale=0.5&overlay_gravity=southeast&overlay_opacity=0.5")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "200", rec.Header().Get("X-Image-Width"))
+	_, ok := h.Overlays.Get(logoId)
+	assert.True(t, ok)
+
+	rec = serve(t, h.Asset, http.MethodGet, testId, "width=200&overlay=watermark&overlay_offset=10,-10")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Get("ETag"), testId)
+
+	rec = serve(t, h.Asset, http.MethodGet, testId, "width=200&overlay="+dataId)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = serve(t, h.Asset, http.MethodGet, testId, "width=200&overlay="+missingId)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	h.Watermark = nil
+	rec = serve(t, h.Asset, http.MethodGet, testId, "width=200&overlay=watermark")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	_, err = NewWatermark("../fixtures/document.pdf")
+	assert.Error(t, err)
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/alexferl/air/asset"
+	"github.com/alexferl/air/storage"
+)
+
+func TestParseRange(t *testing.T) {
+	ranges := []struct {
+		header string
+		start  int64
+		length int64
+		ok     bool
+		err    error
+	}{
+		{"bytes=0-4", 0, 5, true, nil},
+		{"bytes=5-", 5, 5, true, nil},
+		{"bytes=-3", 7, 3, true, nil},
+		{"bytes=-20", 0, 10, true, nil},
+		{"bytes=8-20", 8, 2, true, nil},
+		{"bytes=10-", 0, 0, false, errRangeNotSatisfiable},
+		{"bytes=-0", 0, 0, false, errRangeNotSatisfiable},
+		{"bytes=0-1,3-4", 0, 0, false, nil},
+		{"bytes=4-2", 0, 0, false, nil},
+		{"bytes=a-", 0, 0, false, nil},
+		{"items=0-4", 0, 0, false, nil},
+	}
+
+	for _, r := range ranges {
+		br, err := parseRange(r.header, 10)
+		assert.Equal(t, r.err, err, r.header)
+		if r.ok && assert.NotNil(t, br, r.header) {
+			assert.Equal(t, r.start, br.start, r.header)
+			assert.Equal(t, r.length, br.length, r.header)
+		} else {
+			assert.Nil(t, br, r.header)
+		}
+	}
+}
+
+func TestIsXML(t *testing.T) {
+	types := []struct {
+		contentType string
+		xml         bool
+	}{
+		{"image/svg+xml", true},
+		{"text/xml; charset=utf-8", true},
+		{"application/xml", true},
+		{"application/atom+xml", true},
+		{"text/plain; charset=utf-8", false},
+		{"image/png", false},
+		{"", false},
+	}
+
+	for _, typ := range types {
+		assert.Equal(t, typ.xml, isXML(typ.contentType), typ.contentType)
+	}
+}
+
+func TestAssetRange(t *testing.T) {
+	h, _ := newTestHandler(t, map[string][]byte{testId: []byte("0123456789")})
+
+	rec := serve(t, h.Asset, http.MethodGet, testId, "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "bytes", rec.Header().Get("Accept-Ranges"))
+	assert.Equal(t, "0123456789", rec.Body.String())
+	etag := rec.Header().Get("ETag")
+
+	rec = serve(t, h.Asset, http.MethodGet, testId, "", "Range", "bytes=2-5")
+	assert.Equal(t, http.StatusPartialContent, rec.Code)
+	assert.Equal(t, "bytes 2-5/10", rec.Header().Get("Content-Range"))
+	assert.Equal(t, "4", rec.Header().Get("Content-Length"))
+	assert.Equal(t, "2345", rec.Body.String())
+
+	rec = serve(t, h.Asset, http.MethodGet, testId, "", "Range", "bytes=-3", "If-Range", etag)
+	assert.Equal(t, http.StatusPartialContent, rec.Code)
+	assert.Equal(t, "789", rec.Body.String())
+
+	rec = serve(t, h.Asset, http.MethodGet, testId, "", "Range", "bytes=2-5", "If-Range", `"stale"`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "0123456789", rec.Body.String())
+
+	rec = serve(t, h.Asset, http.MethodGet, testId, "", "Range", "bytes=20-")
+	assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, rec.Code)
+	assert.Equal(t, "bytes */10", rec.Header().Get("Content-Range"))
+}
+
+// untypedStorage reports every object as octet-stream, like stores that
+// weren't given a content type, and counts the bytes read
+type untypedStorage struct {
+	storage.Storage
+	read int64
+}
+
+func (s *untypedStorage) Stat(ctx context.Context, path string) (*storage.Attrs, error) {
+	attrs, err := s.Storage.Stat(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	attrs.ContentType = "application/octet-stream"
+	return attrs, nil
+}
+
+func (s *untypedStorage) Get(ctx context.Context, path string) (io.ReadCloser, error) {
+	return s.GetRange(ctx, path, 0, -1)
+}
+
+func (s *untypedStorage) GetRange(ctx context.Context, path string, offset int64, length int64) (io.ReadCloser, error) {
+	r, err := s.Storage.GetRange(ctx, path, offset, length)
+	if err != nil {
+		return nil, err
+	}
+	b, err := io.ReadAll(r)
+	r.Close()
+	s.read += int64(len(b))
+	return io.NopCloser(bytes.NewReader(b)), err
+}
+
+func TestAssetRangeUntyped(t *testing.T) {
+	b := bytes.Repeat([]byte("0123456789"), 100)
+	h, _ := newTestHandler(t, map[string][]byte{testId: b})
+	s := &untypedStorage{Storage: h.Storage}
+	h.Storage = s
+
+	rec := serve(t, h.Asset, http.MethodGet, testId, "", "Range", "bytes=2-5")
+	assert.Equal(t, http.StatusPartialContent, rec.Code)
+	assert.Equal(t, "text/plain; charset=utf-8", rec.Header().Get(echo.HeaderContentType))
+	assert.Equal(t, "2345", rec.Body.String())
+	// the type is sniffed from the first bytes and only the range is read after
+	assert.Equal(t, int64(asset.SniffLen+4), s.read)
+}
@@ -3,14 +3,35 @@ package handlers
 import (
 	"net/http"
 	"runtime"
+	"time"
 
 	"github.com/davidbyttow/govips/v2/vips"
 	"github.com/labstack/echo/v4"
 )
 
 type Stats struct {
-	Vips    Vips    `json:"vips"`
-	Runtime Runtime `json:"runtime"`
+	Vips        Vips         `json:"vips"`
+	Runtime     Runtime      `json:"runtime"`
+	MemoryCache *MemoryCache `json:"memory_cache,omitempty"`
+	Processing  *Processing  `json:"processing,omitempty"`
+}
+
+type Processing struct {
+	Running   int     `json:"running"`
+	Queued    int     `json:"queued"`
+	Processed uint64  `json:"processed"`
+	Rejected  uint64  `json:"rejected"`
+	TimedOut  uint64  `json:"timed_out"`
+	WaitAvgMs float64 `json:"wait_avg_ms"`
+	WaitMaxMs float64 `json:"wait_max_ms"`
+}
+
+type MemoryCache struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	Entries   int    `json:"entries"`
+	Bytes     int64  `json:"bytes"`
 }
 
 type Runtime struct {
@@ -65,5 +86,31 @@ func (h *Handler) Stats(c echo.Context) error {
 		},
 	}
 
+	if h.Memory != nil {
+		ms := h.Memory.Stats()
+		s.MemoryCache = &MemoryCache{
+			Hits:      ms.Hits,
+			Misses:    ms.Misses,
+			Evictions: ms.Evictions,
+			Entries:   ms.Entries,
+			Bytes:     ms.Bytes,
+		}
+	}
+
+	if h.Pool != nil {
+		ps := h.Pool.Stats()
+		s.Processing = &Processing{
+			Running:   ps.Running,
+			Queued:    ps.Queued,
+			Processed: ps.Acquired,
+			Rejected:  ps.Rejected,
+			TimedOut:  ps.TimedOut,
+			WaitMaxMs: float64(ps.MaxWait) / float64(time.Millisecond),
+		}
+		if ps.Acquired > 0 {
+			s.Processing.WaitAvgMs = float64(ps.TotalWait) / float64(ps.Acquired) / float64(time.Millisecond)
+		}
+	}
+
 	return c.JSON(http.StatusOK, s)
 }
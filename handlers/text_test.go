@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/alexferl/air/asset"
+)
+
+func TestText(t *testing.T) {
+	viper.Set("text-enabled", true)
+	viper.Set("text-max-length", 10)
+	defer viper.Set("text-enabled", nil)
+	defer viper.Set("text-max-length", nil)
+
+	png, err := os.ReadFile("../fixtures/cat.png")
+	assert.NoError(t, err)
+
+	h, _ := newTestHandler(t, map[string][]byte{testId: png})
+	h.Fonts, err = asset.LoadFonts("../fixtures/fonts")
+	assert.NoError(t, err)
+
+	rec := serve(t, h.Asset, http.MethodGet, testId,
+		"width=200&text=air&text_font=DejaVuSansMono&text_gravity=south&text_color=fff&text_offset=0,10")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "200", rec.Header().Get("X-Image-Width"))
+	etag := rec.Header().Get("ETag")
+
+	rec = serve(t, h.Asset, http.MethodGet, testId, "width=200&text=air&text_size=64&text_width=100")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotEqual(t, etag, rec.Header().Get("ETag"))
+
+	requests := []string{
+		"width=200&text=a+long+line+of+text",
+		"width=200&text=%ff",
+		"width=200&text=air&text_font=missing",
+		"width=200&text=air&text_size=0",
+		"width=200&text=air&text_color=transparent",
+		"width=200&text=air&text_gravity=a",
+		"width=200&text=air&text_offset=1",
+		"width=200&text=air&text_width=0",
+	}
+	for _, query := range requests {
+		rec = serve(t, h.Asset, http.MethodGet, testId, query)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+	}
+
+	viper.Set("text-enabled", false)
+	rec = serve(t, h.Asset, http.MethodGet, testId, "width=200&text=air")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -1,8 +1,8 @@
 package handlers
 
 import (
-	"bufio"
 	"context"
+	"errors"
 	"io"
 	"net/http"
 
@@ -40,31 +40,30 @@ func (h *Handler) Upload(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, ErrorResponse{"'file' field is required"})
 	}
 
-	buf := bufio.NewReader(p)
-	f, err := util.CreateTempFile()
+	// the extra byte tells us whether the file is over the limit
+	lmt := io.LimitReader(p, maxFileSize+1)
+	a, err := asset.New(lmt)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, ErrorResponse{"Error creating temporary file"})
-	}
-	defer util.CleanupTempFile(f)
-
-	lmt := io.LimitReader(buf, maxFileSize+1)
-	written, err := io.Copy(f, lmt)
-	if err != nil && err != io.EOF {
-		log.Error().Msgf("Failed to write to temp file: %v", err)
-		return c.JSON(http.StatusInternalServerError, ErrorResponse{"Error writing temporary file"})
+		if err.Error() == "http: request body too large" {
+			return c.JSON(http.StatusRequestEntityTooLarge, ErrorResponse{"File is too large"})
+		}
+		log.Error().Msgf("Failed to create asset: %v", err)
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{"Error saving file"})
 	}
+	defer util.CleanupTempFile(a.File)
 
-	if written > maxFileSize {
+	if a.Size > maxFileSize {
 		return c.JSON(http.StatusRequestEntityTooLarge, ErrorResponse{"File is too large"})
 	}
 
-	f.Seek(0, 0) // rewind file
-
-	a, err := asset.New(f)
+	err = a.Sanitize()
 	if err != nil {
+		if errors.Is(err, asset.ErrInvalidSVG) {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{"Invalid SVG"})
+		}
+		log.Error().Msgf("Failed to sanitize asset: %v", err)
 		return c.JSON(http.StatusInternalServerError, ErrorResponse{"Error saving file"})
 	}
-	defer util.CleanupTempFile(a.File)
 
 	ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("file-upload-timeout"))
 	defer cancel()
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/alexferl/air/asset"
+	"github.com/alexferl/air/util"
+)
+
+func TestUpload(t *testing.T) {
+	viper.Set("max-file-size", 1)
+	defer viper.Set("max-file-size", nil)
+
+	h, dir := newTestHandler(t, nil)
+	e := echo.New()
+
+	upload := func(name string) *httptest.ResponseRecorder {
+		b, err := os.ReadFile("../fixtures/" + name)
+		assert.NoError(t, err)
+
+		body := &bytes.Buffer{}
+		w := multipart.NewWriter(body)
+		part, err := w.CreateFormFile("file", name)
+		assert.NoError(t, err)
+		_, err = part.Write(b)
+		assert.NoError(t, err)
+		assert.NoError(t, w.Close())
+
+		req := httptest.NewRequest(http.MethodPost, "/upload", body)
+		req.Header.Set(echo.HeaderContentType, w.FormDataContentType())
+		rec := httptest.NewRecorder()
+		assert.NoError(t, h.Upload(e.NewContext(req, rec)))
+		return rec
+	}
+
+	rec := upload("cat.png")
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "/assets/"+testId, rec.Header().Get("Location"))
+
+	path, _ := util.GetFullPathFromSha256(testId)
+	stored, err := os.ReadFile(filepath.Join(dir, path))
+	assert.NoError(t, err)
+	orig, _ := os.ReadFile("../fixtures/cat.png")
+	assert.Equal(t, orig, stored)
+
+	rec = upload("tiger.png")
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+}
+
+func TestUploadSVG(t *testing.T) {
+	h, dir := newTestHandler(t, nil)
+	e := echo.New()
+
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	part, err := w.CreateFormFile("file", "logo.svg")
+	assert.NoError(t, err)
+	b, err := os.ReadFile("../fixtures/logo.svg")
+	assert.NoError(t, err)
+	_, err = part.Write(b)
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/upload", body)
+	req.Header.Set(echo.HeaderContentType, w.FormDataContentType())
+	rec := httptest.NewRecorder()
+	assert.NoError(t, h.Upload(e.NewContext(req, rec)))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	id := strings.TrimPrefix(rec.Header().Get("Location"), "/assets/")
+	path, _ := util.GetFullPathFromSha256(id)
+	stored, err := os.ReadFile(filepath.Join(dir, path))
+	assert.NoError(t, err)
+	assert.NotContains(t, string(stored), "script")
+	assert.NotContains(t, string(stored), "example.com")
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	rec = httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetPath("/:id")
+	c.SetParamNames("id")
+	c.SetParamValues(id)
+	assert.NoError(t, h.Asset(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, asset.SVG, rec.Header().Get(echo.HeaderContentType))
+	assert.Equal(t, svgContentSecurityPolicy, rec.Header().Get("Content-Security-Policy"))
+	assert.Equal(t, stored, rec.Body.Bytes())
+}
@@ -0,0 +1,127 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrQueueFull is returned when the maximum amount of callers are already waiting
+	ErrQueueFull = errors.New("processing queue is full")
+	// ErrQueueTimeout is returned when a caller waited longer than the maximum queue wait
+	ErrQueueTimeout = errors.New("timed out waiting in processing queue")
+)
+
+type Opts struct {
+	// MaxConcurrency is the maximum amount of concurrent jobs, 0 means no limit
+	MaxConcurrency int
+	// MaxQueue is the maximum amount of jobs waiting for a slot
+	MaxQueue int
+	// MaxQueueWait is how long a job can wait for a slot, 0 means forever
+	MaxQueueWait time.Duration
+}
+
+// Stats holds the counters of a Pool
+type Stats struct {
+	Running   int
+	Queued    int
+	Acquired  uint64
+	Rejected  uint64
+	TimedOut  uint64
+	TotalWait time.Duration
+	MaxWait   time.Duration
+}
+
+// Pool bounds the amount of jobs running concurrently, queueing the excess
+type Pool struct {
+	*Opts
+	slots chan struct{}
+	mu    sync.Mutex
+	stats Stats
+}
+
+func New(opts *Opts) *Pool {
+	p := &Pool{Opts: opts}
+	if opts.MaxConcurrency > 0 {
+		p.slots = make(chan struct{}, opts.MaxConcurrency)
+	}
+	return p
+}
+
+// Acquire waits for a slot to run a job. The returned function must be called
+// to release the slot once the job is done.
+func (p *Pool) Acquire(ctx context.Context) (func(), error) {
+	if p.slots == nil {
+		p.record(0)
+		return func() {}, nil
+	}
+
+	select {
+	case p.slots <- struct{}{}:
+		p.record(0)
+		return p.release, nil
+	default:
+	}
+
+	p.mu.Lock()
+	if p.stats.Queued >= p.MaxQueue {
+		p.stats.Rejected++
+		p.mu.Unlock()
+		return nil, ErrQueueFull
+	}
+	p.stats.Queued++
+	p.mu.Unlock()
+
+	defer func() {
+		p.mu.Lock()
+		p.stats.Queued--
+		p.mu.Unlock()
+	}()
+
+	var timeout <-chan time.Time
+	if p.MaxQueueWait > 0 {
+		t := time.NewTimer(p.MaxQueueWait)
+		defer t.Stop()
+		timeout = t.C
+	}
+
+	start := time.Now()
+	select {
+	case p.slots <- struct{}{}:
+		p.record(time.Since(start))
+		return p.release, nil
+	case <-timeout:
+		p.mu.Lock()
+		p.stats.TimedOut++
+		p.mu.Unlock()
+		return nil, ErrQueueTimeout
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (p *Pool) Stats() Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s := p.stats
+	s.Running = len(p.slots)
+	return s
+}
+
+func (p *Pool) release() {
+	<-p.slots
+}
+
+func (p *Pool) record(wait time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.stats.Acquired++
+	p.stats.TotalWait += wait
+	if wait > p.stats.MaxWait {
+		p.stats.MaxWait = wait
+	}
+}
@@ -0,0 +1,58 @@
+package pool
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPool(t *testing.T) {
+	p := New(&Opts{MaxConcurrency: 1, MaxQueue: 1, MaxQueueWait: 20 * time.Millisecond})
+	ctx := context.Background()
+
+	release, err := p.Acquire(ctx)
+	assert.NoError(t, err)
+
+	// waits in the queue until it times out
+	_, err = p.Acquire(ctx)
+	assert.Equal(t, ErrQueueTimeout, err)
+
+	done := make(chan error)
+	go func() {
+		r, err := p.Acquire(ctx)
+		if err == nil {
+			r()
+		}
+		done <- err
+	}()
+
+	for p.Stats().Queued == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// the queue is full
+	_, err = p.Acquire(ctx)
+	assert.Equal(t, ErrQueueFull, err)
+
+	release()
+	assert.NoError(t, <-done)
+
+	s := p.Stats()
+	assert.Equal(t, 0, s.Running)
+	assert.Equal(t, 0, s.Queued)
+	assert.Equal(t, uint64(2), s.Acquired)
+	assert.Equal(t, uint64(1), s.Rejected)
+	assert.Equal(t, uint64(1), s.TimedOut)
+	assert.Greater(t, int64(s.MaxWait), int64(0))
+}
+
+func TestPoolUnlimited(t *testing.T) {
+	p := New(&Opts{})
+	for i := 0; i < 10; i++ {
+		_, err := p.Acquire(context.Background())
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, uint64(10), p.Stats().Acquired)
+}
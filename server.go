@@ -27,12 +27,41 @@ func main() {
 		panic(err)
 	}
 
+	variants, err := factories.Variants(ctx, storage)
+	if err != nil {
+		panic(err)
+	}
+
+	watermark, err := factories.Watermark()
+	if err != nil {
+		panic(err)
+	}
+
+	// fonts must be configured before libvips draws any text
+	fonts, err := factories.Fonts()
+	if err != nil {
+		panic(err)
+	}
+
 	s := server.New()
-	h := &handlers.Handler{Storage: storage}
+	h := &handlers.Handler{
+		Storage:   storage,
+		Variants:  variants,
+		Memory:    factories.Memory(),
+		Pool:      factories.Pool(),
+		Overlays:  factories.Overlays(),
+		Watermark: watermark,
+		Fonts:     fonts,
+	}
 	r := &router.Router{
 		Routes: []router.Route{
 			{"Root", http.MethodGet, "/", h.Root},
+			{"ListAssets", http.MethodGet, "/assets", h.ListAssets},
 			{"Asset", http.MethodGet, "/assets/:id", h.Asset},
+			{"HeadAsset", http.MethodHead, "/assets/:id", h.HeadAsset},
+			{"DeleteAsset", http.MethodDelete, "/assets/:id", h.DeleteAsset},
+			{"Metadata", http.MethodGet, "/assets/:id/metadata", h.Metadata},
+			{"UpdateMetadata", http.MethodPatch, "/assets/:id/metadata", h.UpdateMetadata},
 			{"Stats", http.MethodGet, "/stats", h.Stats},
 			{"Upload", http.MethodPost, "/upload", h.Upload},
 			{"FavIcon", http.MethodGet, "/favicon.ico", func(c echo.Context) error {
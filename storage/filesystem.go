@@ -2,14 +2,20 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"io"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/rs/zerolog/log"
 
 	"github.com/alexferl/air/asset"
 )
 
+// errListDone stops walking the filesystem once a page is full
+var errListDone = errors.New("list done")
+
 type FilesystemOpts struct {
 	Path string
 }
@@ -28,6 +34,9 @@ func (fs *Filesystem) Get(_ context.Context, path string) (io.ReadCloser, error)
 	fullPath := fs.getFullPath(path)
 	f, err := os.OpenFile(fullPath, os.O_RDONLY, 0o644)
 	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
 		log.Error().Msgf("Failed to open file for reading: %v", err)
 		return nil, err
 	}
@@ -35,6 +44,26 @@ func (fs *Filesystem) Get(_ context.Context, path string) (io.ReadCloser, error)
 	return f, nil
 }
 
+func (fs *Filesystem) GetRange(ctx context.Context, path string, offset int64, length int64) (io.ReadCloser, error) {
+	f, err := fs.Get(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	file := f.(*os.File)
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		log.Error().Msgf("Failed to seek file: %v", err)
+		return nil, err
+	}
+
+	if length < 0 {
+		return file, nil
+	}
+
+	return &limitedReadCloser{io.LimitReader(file, length), file}, nil
+}
+
 func (fs *Filesystem) Put(_ context.Context, a *asset.Asset) error {
 	folderPath := fs.getFullPath(a.PathPrefix)
 	err := os.MkdirAll(folderPath, os.ModePerm)
@@ -62,6 +91,105 @@ func (fs *Filesystem) Put(_ context.Context, a *asset.Asset) error {
 	return nil
 }
 
+func (fs *Filesystem) Delete(_ context.Context, path string) error {
+	fullPath := fs.getFullPath(path)
+	err := os.Remove(fullPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ErrNotFound
+		}
+		log.Error().Msgf("Failed to remove file: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// Stat doesn't know the content type of files, it's left empty so it's only
+// sniffed where needed
+func (fs *Filesystem) Stat(_ context.Context, path string) (*Attrs, error) {
+	fi, err := os.Stat(fs.getFullPath(path))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		log.Error().Msgf("Failed to stat file: %v", err)
+		return nil, err
+	}
+	if !fi.Mode().IsRegular() {
+		return nil, ErrNotFound
+	}
+
+	return &Attrs{
+		Size:         fi.Size(),
+		LastModified: fi.ModTime(),
+	}, nil
+}
+
+// List walks the directory tree in lexical order, which keeps the aa/bb/cc/ sharded
+// layout sorted, skipping any directory that can't contain paths matching prefix or
+// after cursor. Unreadable directories are skipped.
+func (fs *Filesystem) List(_ context.Context, prefix string, cursor string, limit int) (*ObjectList, error) {
+	list := &ObjectList{}
+	if limit <= 0 {
+		return list, nil
+	}
+
+	root := filepath.Clean(fs.Path)
+	err := filepath.WalkDir(root, func(p string, d os.DirEntry, err error) error {
+		if p == root {
+			return err
+		}
+		if err != nil {
+			log.Warn().Msgf("Skipping %s: %v", p, err)
+			return filepath.SkipDir
+		}
+
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if d.IsDir() {
+			if !mayContain(rel+"/", prefix, cursor) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !d.Type().IsRegular() || rel <= cursor || !strings.HasPrefix(rel, prefix) {
+			return nil
+		}
+
+		if len(list.Objects) == limit {
+			list.NextCursor = list.Objects[limit-1].Path
+			return errListDone
+		}
+
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		list.Objects = append(list.Objects, &Object{
+			Path:         rel,
+			Size:         fi.Size(),
+			LastModified: fi.ModTime(),
+		})
+
+		return nil
+	})
+	if err != nil && err != errListDone {
+		if os.IsNotExist(err) {
+			return list, nil
+		}
+		log.Error().Msgf("Failed to list files: %v", err)
+		return nil, err
+	}
+
+	return list, nil
+}
+
 func (fs *Filesystem) getFullPath(name string) string {
 	fullPath := fs.Path
 	if len(fullPath) > 0 && string(fullPath[len(fullPath)-1]) != "/" {
@@ -72,3 +200,16 @@ func (fs *Filesystem) getFullPath(name string) string {
 
 	return fullPath
 }
+
+// mayContain reports whether dir can hold paths starting with prefix that sort after cursor
+func mayContain(dir string, prefix string, cursor string) bool {
+	if !strings.HasPrefix(dir, prefix) && !strings.HasPrefix(prefix, dir) {
+		return false
+	}
+	return dir > cursor || strings.HasPrefix(cursor, dir)
+}
+
+type limitedReadCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -6,6 +6,7 @@ import (
 
 	"cloud.google.com/go/storage"
 	"github.com/rs/zerolog/log"
+	"google.golang.org/api/iterator"
 
 	"github.com/alexferl/air/asset"
 )
@@ -48,6 +49,9 @@ func NewGCloud(ctx context.Context, opts *GCloudOpts) (Storage, error) {
 func (gc *GCloud) Get(ctx context.Context, path string) (io.ReadCloser, error) {
 	r, err := gc.bucket.Object(path).NewReader(ctx)
 	if err != nil {
+		if err == storage.ErrObjectNotExist {
+			return nil, ErrNotFound
+		}
 		log.Error().Msgf("Failed to read object from bucket: %v", err)
 		return nil, err
 	}
@@ -55,8 +59,22 @@ func (gc *GCloud) Get(ctx context.Context, path string) (io.ReadCloser, error) {
 	return r, nil
 }
 
+func (gc *GCloud) GetRange(ctx context.Context, path string, offset int64, length int64) (io.ReadCloser, error) {
+	r, err := gc.bucket.Object(path).NewRangeReader(ctx, offset, length)
+	if err != nil {
+		if err == storage.ErrObjectNotExist {
+			return nil, ErrNotFound
+		}
+		log.Error().Msgf("Failed to read object range from bucket: %v", err)
+		return nil, err
+	}
+
+	return r, nil
+}
+
 func (gc *GCloud) Put(ctx context.Context, a *asset.Asset) error {
 	wc := gc.bucket.Object(a.Path).NewWriter(ctx)
+	wc.ContentType = a.ContentType
 	if _, err := io.Copy(wc, a.File); err != nil {
 		return err
 	}
@@ -67,3 +85,78 @@ func (gc *GCloud) Put(ctx context.Context, a *asset.Asset) error {
 
 	return nil
 }
+
+func (gc *GCloud) Delete(ctx context.Context, path string) error {
+	err := gc.bucket.Object(path).Delete(ctx)
+	if err != nil {
+		if err == storage.ErrObjectNotExist {
+			return ErrNotFound
+		}
+		log.Error().Msgf("Failed to delete object from bucket: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+func (gc *GCloud) Stat(ctx context.Context, path string) (*Attrs, error) {
+	attrs, err := gc.bucket.Object(path).Attrs(ctx)
+	if err != nil {
+		if err == storage.ErrObjectNotExist {
+			return nil, ErrNotFound
+		}
+		log.Error().Msgf("Failed to get object attributes from bucket: %v", err)
+		return nil, err
+	}
+
+	return &Attrs{
+		Size:         attrs.Size,
+		LastModified: attrs.Updated,
+		ContentType:  attrs.ContentType,
+	}, nil
+}
+
+func (gc *GCloud) List(ctx context.Context, prefix string, cursor string, limit int) (*ObjectList, error) {
+	list := &ObjectList{}
+	if limit <= 0 {
+		return list, nil
+	}
+
+	q := &storage.Query{
+		Prefix:      prefix,
+		StartOffset: cursor,
+	}
+	if err := q.SetAttrSelection([]string{"Name", "Size", "Updated"}); err != nil {
+		return nil, err
+	}
+
+	it := gc.bucket.Objects(ctx, q)
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			log.Error().Msgf("Failed to list objects from bucket: %v", err)
+			return nil, err
+		}
+
+		// StartOffset is inclusive
+		if attrs.Name == cursor {
+			continue
+		}
+
+		if len(list.Objects) == limit {
+			list.NextCursor = list.Objects[limit-1].Path
+			break
+		}
+
+		list.Objects = append(list.Objects, &Object{
+			Path:         attrs.Name,
+			Size:         attrs.Size,
+			LastModified: attrs.Updated,
+		})
+	}
+
+	return list, nil
+}
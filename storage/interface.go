@@ -2,12 +2,46 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"io"
+	"time"
 
 	"github.com/alexferl/air/asset"
 )
 
+// ErrNotFound is returned when the requested path doesn't exist in storage
+var ErrNotFound = errors.New("object not found")
+
+// Attrs holds the metadata of a stored object
+type Attrs struct {
+	Size         int64
+	LastModified time.Time
+	// ContentType is empty when the store doesn't keep it
+	ContentType string
+}
+
+// Object holds the attributes of a listed object
+type Object struct {
+	Path         string
+	Size         int64
+	LastModified time.Time
+}
+
+// ObjectList holds a page of listed objects. NextCursor is empty
+// when there are no more objects to list.
+type ObjectList struct {
+	Objects    []*Object
+	NextCursor string
+}
+
 type Storage interface {
 	Get(ctx context.Context, path string) (io.ReadCloser, error)
+	// GetRange reads length bytes starting at offset, a length of -1 reads to the end
+	GetRange(ctx context.Context, path string, offset int64, length int64) (io.ReadCloser, error)
 	Put(ctx context.Context, a *asset.Asset) error
+	Delete(ctx context.Context, path string) error
+	Stat(ctx context.Context, path string) (*Attrs, error)
+	// List returns up to limit objects whose path starts with prefix, in
+	// lexical order, starting after the path given as cursor
+	List(ctx context.Context, prefix string, cursor string, limit int) (*ObjectList, error)
 }
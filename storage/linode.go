@@ -41,6 +41,22 @@ func (l *Linode) Get(ctx context.Context, path string) (io.ReadCloser, error) {
 	return l.s3.Get(ctx, path)
 }
 
+func (l *Linode) GetRange(ctx context.Context, path string, offset int64, length int64) (io.ReadCloser, error) {
+	return l.s3.GetRange(ctx, path, offset, length)
+}
+
 func (l *Linode) Put(ctx context.Context, a *asset.Asset) error {
 	return l.s3.Put(ctx, a)
 }
+
+func (l *Linode) Delete(ctx context.Context, path string) error {
+	return l.s3.Delete(ctx, path)
+}
+
+func (l *Linode) Stat(ctx context.Context, path string) (*Attrs, error) {
+	return l.s3.Stat(ctx, path)
+}
+
+func (l *Linode) List(ctx context.Context, prefix string, cursor string, limit int) (*ObjectList, error) {
+	return l.s3.List(ctx, prefix, cursor, limit)
+}
@@ -2,11 +2,14 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
@@ -81,6 +84,9 @@ func (s *S3) Get(ctx context.Context, path string) (io.ReadCloser, error) {
 		Key:    aws.String(path),
 	})
 	if err != nil {
+		if isNotFound(err) {
+			return nil, ErrNotFound
+		}
 		log.Error().Msgf("Failed to get object from bucket: %v", err)
 		return nil, err
 	}
@@ -88,11 +94,34 @@ func (s *S3) Get(ctx context.Context, path string) (io.ReadCloser, error) {
 	return resp.Body, nil
 }
 
+func (s *S3) GetRange(ctx context.Context, path string, offset int64, length int64) (io.ReadCloser, error) {
+	rng := fmt.Sprintf("bytes=%d-", offset)
+	if length >= 0 {
+		rng = fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)
+	}
+
+	resp, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(path),
+		Range:  aws.String(rng),
+	})
+	if err != nil {
+		if isNotFound(err) {
+			return nil, ErrNotFound
+		}
+		log.Error().Msgf("Failed to get object range from bucket: %v", err)
+		return nil, err
+	}
+
+	return resp.Body, nil
+}
+
 func (s *S3) Put(ctx context.Context, a *asset.Asset) error {
 	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
 		Bucket:       aws.String(s.Bucket),
 		Key:          aws.String(a.Path),
 		StorageClass: types.StorageClass(s.StorageClass),
+		ContentType:  aws.String(a.ContentType),
 		Body:         a.File,
 	})
 	if err != nil {
@@ -102,3 +131,74 @@ func (s *S3) Put(ctx context.Context, a *asset.Asset) error {
 
 	return nil
 }
+
+func (s *S3) Delete(ctx context.Context, path string) error {
+	// DeleteObject succeeds even if the key doesn't exist so check first
+	if _, err := s.Stat(ctx, path); err != nil {
+		return err
+	}
+
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(path),
+	})
+	if err != nil {
+		log.Error().Msgf("Failed to delete object from bucket: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+func (s *S3) Stat(ctx context.Context, path string) (*Attrs, error) {
+	resp, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(path),
+	})
+	if err != nil {
+		if isNotFound(err) {
+			return nil, ErrNotFound
+		}
+		log.Error().Msgf("Failed to head object from bucket: %v", err)
+		return nil, err
+	}
+
+	return &Attrs{
+		Size:         resp.ContentLength,
+		LastModified: aws.ToTime(resp.LastModified),
+		ContentType:  aws.ToString(resp.ContentType),
+	}, nil
+}
+
+func (s *S3) List(ctx context.Context, prefix string, cursor string, limit int) (*ObjectList, error) {
+	resp, err := s.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+		Bucket:     aws.String(s.Bucket),
+		Prefix:     aws.String(prefix),
+		StartAfter: aws.String(cursor),
+		MaxKeys:    int32(limit),
+	})
+	if err != nil {
+		log.Error().Msgf("Failed to list objects from bucket: %v", err)
+		return nil, err
+	}
+
+	list := &ObjectList{}
+	for _, o := range resp.Contents {
+		list.Objects = append(list.Objects, &Object{
+			Path:         aws.ToString(o.Key),
+			Size:         o.Size,
+			LastModified: aws.ToTime(o.LastModified),
+		})
+	}
+
+	if resp.IsTruncated && len(list.Objects) > 0 {
+		list.NextCursor = list.Objects[len(list.Objects)-1].Path
+	}
+
+	return list, nil
+}
+
+func isNotFound(err error) bool {
+	var re *awshttp.ResponseError
+	return errors.As(err, &re) && re.HTTPStatusCode() == http.StatusNotFound
+}